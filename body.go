@@ -0,0 +1,94 @@
+package exhtml
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodySize is the max body size of a Fetcher built without
+// WithMaxBodySize.
+const DefaultMaxBodySize = 10 << 20
+
+// DefaultContentTypes are the HTML and feed media types, pass them to
+// WithContentTypes to refuse anything else.
+var DefaultContentTypes = []string{
+	"text/html",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/xml",
+	"text/xml",
+	"application/feed+json",
+}
+
+// WithContentTypes makes the Fetcher refuse responses whose media type is
+// not one of types with a ContentTypeError before the body is read.
+// A type may end with "/*" to allow a whole family, e.g. "text/*".
+// Responses without Content-Type are accepted.
+func WithContentTypes(types ...string) Option {
+	return func(f *Fetcher) {
+		f.contentTypes = types
+	}
+}
+
+// ContentTypeError is returned when a response has a media type that is not
+// allowed by WithContentTypes.
+type ContentTypeError struct {
+	URL         string
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("reading %s: content type %q not allowed", e.URL, e.ContentType)
+}
+
+// allowedType reports whether the Content-Type header value ct is allowed.
+func (f *Fetcher) allowedType(ct string) bool {
+	if len(f.contentTypes) == 0 || ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	for _, t := range f.contentTypes {
+		t = strings.ToLower(t)
+		if t == mt || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBody reads the body of resp up to the max body size. It refuses
+// disallowed content types and bodies announced larger than the limit
+// without reading them.
+func (f *Fetcher) readBody(src string, resp *http.Response) ([]byte, error) {
+	return f.readBodyLimit(src, resp, f.maxBodySize)
+}
+
+// readBodyLimit is like readBody with a max body size of limit, <= 0 means
+// no limit.
+func (f *Fetcher) readBodyLimit(src string, resp *http.Response, limit int64) ([]byte, error) {
+	if ct := resp.Header.Get("Content-Type"); !f.allowedType(ct) {
+		return nil, &ContentTypeError{URL: src, ContentType: ct}
+	}
+	if limit <= 0 {
+		return ioutil.ReadAll(resp.Body)
+	}
+	if resp.ContentLength > limit {
+		return nil, &TooLargeError{URL: src, Limit: limit}
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limit {
+		return nil, &TooLargeError{URL: src, Limit: limit}
+	}
+	return raw, nil
+}
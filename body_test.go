@@ -0,0 +1,63 @@
+package exhtml
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentTypeError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		fmt.Fprint(w, "not html")
+	}))
+	defer ts.Close()
+
+	_, err := NewFetcher(WithContentTypes(DefaultContentTypes...)).ExtractLinks(ts.URL)
+	var ce *ContentTypeError
+	if !errors.As(err, &ce) || ce.ContentType != "video/mp4" {
+		t.Errorf("want *ContentTypeError for video/mp4, got: %v", err)
+	}
+	if _, err := NewFetcher(WithContentTypes("video/*")).ExtractLinks(ts.URL); err != nil {
+		t.Errorf("want video/* allowed, got: %v", err)
+	}
+	if _, err := ExtractLinks(ts.URL); err != nil {
+		t.Errorf("want any type allowed by default, got: %v", err)
+	}
+}
+
+func TestTooLargeContentLength(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000000")
+		w.(http.Flusher).Flush()
+	}))
+	defer ts.Close()
+
+	_, err := ExtractLinks(ts.URL)
+	var te *TooLargeError
+	if !errors.As(err, &te) || te.Limit != DefaultMaxBodySize {
+		t.Errorf("want *TooLargeError with the default limit, got: %v", err)
+	}
+}
+
+func TestAllowedType(t *testing.T) {
+	f := NewFetcher(WithContentTypes("text/html", "application/rss+xml"))
+	tests := []struct {
+		ct   string
+		want bool
+	}{
+		{"", true},
+		{"text/html", true},
+		{"Text/HTML; charset=gbk", true},
+		{"application/rss+xml", true},
+		{"application/octet-stream", false},
+		{"not a type", false},
+	}
+	for _, tc := range tests {
+		if got := f.allowedType(tc.ct); got != tc.want {
+			t.Errorf("%q: want %v, got: %v", tc.ct, tc.want, got)
+		}
+	}
+}
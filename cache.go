@@ -0,0 +1,235 @@
+package exhtml
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxCacheBodySize is the largest body stored when the Fetcher has no
+// max body size.
+const maxCacheBodySize = 10 << 20
+
+// CacheEntry is a stored response with the validators sent on revalidation.
+type CacheEntry struct {
+	URL          string
+	Header       http.Header
+	Body         []byte
+	ETag         string
+	LastModified string
+	Stored       time.Time
+}
+
+// Cache stores responses by URL. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, e *CacheEntry) error
+	Delete(key string) error
+}
+
+// WithCache makes the Fetcher store responses carrying an ETag or
+// Last-Modified header in c, revalidate them with If-None-Match and
+// If-Modified-Since and serve 304 Not Modified answers from c.
+func WithCache(c Cache) Option {
+	return func(f *Fetcher) {
+		f.cache = c
+	}
+}
+
+// MemoryCache is a Cache kept in memory.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]*CacheEntry
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]*CacheEntry{}}
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(key string, e *CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = e
+	return nil
+}
+
+// Delete implements Cache.
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	return nil
+}
+
+// DiskCache is a Cache storing every entry as a JSON file in a directory.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a DiskCache in dir, creating dir if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithMessage(err, "exhtml: NewDiskCache")
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get implements Cache.
+func (c *DiskCache) Get(key string) (*CacheEntry, bool) {
+	b, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	e := &CacheEntry{}
+	if err := json.Unmarshal(b, e); err != nil || e.URL != key {
+		return nil, false
+	}
+	return e, true
+}
+
+// Set implements Cache. The file is written to a temporary name first,
+// so readers never see a partial entry.
+func (c *DiskCache) Set(key string, e *CacheEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return errors.WithMessage(err, "exhtml: DiskCache.Set")
+	}
+	tmp, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return errors.WithMessage(err, "exhtml: DiskCache.Set")
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.WithMessage(err, "exhtml: DiskCache.Set")
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return errors.WithMessage(err, "exhtml: DiskCache.Set")
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return errors.WithMessage(err, "exhtml: DiskCache.Set")
+	}
+	return nil
+}
+
+// Delete implements Cache.
+func (c *DiskCache) Delete(key string) error {
+	err := os.Remove(c.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// doCached sends req through the cache of the Fetcher if there is one.
+// A failure to store a response in the cache is returned.
+func (f *Fetcher) doCached(req *http.Request) (*http.Response, error) {
+	if f.cache == nil || req.Method != "GET" {
+		return f.client.Do(req)
+	}
+	key := req.URL.String()
+	e, ok := f.cache.Get(key)
+	if ok {
+		if e.ETag != "" {
+			req.Header.Set("If-None-Match", e.ETag)
+		}
+		if e.LastModified != "" {
+			req.Header.Set("If-Modified-Since", e.LastModified)
+		}
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		h := e.Header.Clone()
+		for k, vs := range resp.Header {
+			h[k] = vs
+		}
+		// refresh the entry with the validators of the 304
+		refreshed := *e
+		refreshed.Header = h
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			refreshed.ETag = etag
+		}
+		if lastMod := resp.Header.Get("Last-Modified"); lastMod != "" {
+			refreshed.LastModified = lastMod
+		}
+		refreshed.Stored = time.Now()
+		if err := f.cache.Set(key, &refreshed); err != nil {
+			return nil, errors.WithMessage(err, "exhtml: cache")
+		}
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header = h.Clone()
+		resp.ContentLength = int64(len(e.Body))
+		resp.Body = ioutil.NopCloser(bytes.NewReader(e.Body))
+		return resp, nil
+	}
+	etag, lastMod := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastMod == "") {
+		return resp, nil
+	}
+
+	// Read the body ahead to store it, bodies over the limit are passed
+	// on uncached.
+	limit := int64(maxCacheBodySize)
+	if f.maxBodySize > 0 {
+		limit = f.maxBodySize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	err = f.cache.Set(key, &CacheEntry{
+		URL:          key,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		ETag:         etag,
+		LastModified: lastMod,
+		Stored:       time.Now(),
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "exhtml: cache")
+	}
+	return resp, nil
+}
@@ -0,0 +1,121 @@
+package exhtml
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetcherCache(t *testing.T) {
+	full, notModified := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `<a href="/a">a</a>`)
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(WithCache(NewMemoryCache()))
+	for i := 0; i < 3; i++ {
+		links, err := f.ExtractLinks(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(links) != 1 || links[0] != ts.URL+"/a" {
+			t.Errorf("want [%s/a], got: %v", ts.URL, links)
+		}
+	}
+	if full != 1 || notModified != 2 {
+		t.Errorf("want 1 full and 2 conditional responses, got: %d, %d", full, notModified)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	c, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "https://example.com/rss.xml"
+	if _, ok := c.Get(key); ok {
+		t.Fatal("want empty cache")
+	}
+	e := &CacheEntry{
+		URL:          key,
+		Header:       http.Header{"Content-Type": {"application/rss+xml"}},
+		Body:         []byte("<rss/>"),
+		LastModified: "Sat, 09 Oct 2021 01:47:33 GMT",
+	}
+	if err := c.Set(key, e); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c.Get(key)
+	if !ok || string(got.Body) != "<rss/>" || got.LastModified != e.LastModified ||
+		got.Header.Get("Content-Type") != "application/rss+xml" {
+		t.Errorf("want %+v, got: %+v", e, got)
+	}
+	if err := c.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Errorf("want entry deleted")
+	}
+}
+
+func TestFetcherCacheRefresh(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			w.Header().Set("ETag", `"v2"`)
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `<a href="/a">a</a>`)
+	}))
+	defer ts.Close()
+
+	c := NewMemoryCache()
+	f := NewFetcher(WithCache(c))
+	if _, err := f.ExtractLinks(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := c.Get(ts.URL)
+	time.Sleep(10 * time.Millisecond)
+	if _, err := f.ExtractLinks(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := c.Get(ts.URL)
+	if e.ETag != `"v2"` || e.Header.Get("Cache-Control") != "max-age=60" ||
+		!e.Stored.After(first.Stored) || string(e.Body) != `<a href="/a">a</a>` {
+		t.Errorf("want entry refreshed by the 304, got: %+v", e)
+	}
+}
+
+type failingCache struct{ *MemoryCache }
+
+var errCacheFull = errors.New("cache full")
+
+func (failingCache) Set(key string, e *CacheEntry) error {
+	return errCacheFull
+}
+
+func TestFetcherCacheSetError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `<a href="/a">a</a>`)
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(WithCache(failingCache{NewMemoryCache()}))
+	if _, err := f.ExtractLinks(ts.URL); !errors.Is(err, errCacheFull) {
+		t.Errorf("want %v, got: %v", errCacheFull, err)
+	}
+}
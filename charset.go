@@ -0,0 +1,41 @@
+package exhtml
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html/charset"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// WithCharsetDetection switches transcoding of HTML bodies to UTF-8,
+// it is on by default.
+func WithCharsetDetection(on bool) Option {
+	return func(f *Fetcher) {
+		f.detectCharset = on
+	}
+}
+
+// DecodeToUTF8 transcodes raw to UTF-8 and returns it with the name of the
+// detected encoding, e.g. "gbk", "big5", "shift_jis" or "euc-kr".
+// The encoding is taken from a byte order mark, the contentType header
+// value, <meta charset> or <meta http-equiv="Content-Type"> in that order,
+// and guessed from the content if none of them is present. A body that
+// is valid UTF-8 is kept as is unless a BOM or contentType says otherwise.
+func DecodeToUTF8(raw []byte, contentType string) ([]byte, string, error) {
+	enc, name, certain := charset.DetermineEncoding(raw, contentType)
+	// the guess only looks at the first 1024 bytes, which are often ASCII
+	if !certain && utf8.Valid(raw) {
+		name = "utf-8"
+	}
+	if name == "utf-8" {
+		return bytes.TrimPrefix(raw, utf8BOM), name, nil
+	}
+	out, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return nil, name, errors.WithMessagef(err, "exhtml: decode %s", name)
+	}
+	return out, name, nil
+}
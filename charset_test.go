@@ -0,0 +1,96 @@
+package exhtml
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+func encode(t *testing.T, e encoding.Encoding, s string) []byte {
+	b, err := e.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeToUTF8(t *testing.T) {
+	tests := []struct {
+		raw         []byte
+		contentType string
+		want        string
+		wantCharset string
+	}{
+		{
+			encode(t, simplifiedchinese.GBK, `<meta charset="gbk"><p>越通社</p>`),
+			"text/html",
+			`<meta charset="gbk"><p>越通社</p>`,
+			"gbk",
+		},
+		{
+			encode(t, traditionalchinese.Big5, `<p>中央社</p>`),
+			"text/html; charset=big5",
+			`<p>中央社</p>`,
+			"big5",
+		},
+		{
+			encode(t, japanese.ShiftJIS, `<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS"><p>日経</p>`),
+			"",
+			`<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS"><p>日経</p>`,
+			"shift_jis",
+		},
+		{
+			append(append([]byte{}, utf8BOM...), "<p>ok</p>"...),
+			"",
+			"<p>ok</p>",
+			"utf-8",
+		},
+		{
+			// no declaration and only ASCII in the first 1024 bytes
+			[]byte("<script>" + strings.Repeat("var a = 1;\n", 150) + "</script><p>中文新闻</p>"),
+			"text/html",
+			"<script>" + strings.Repeat("var a = 1;\n", 150) + "</script><p>中文新闻</p>",
+			"utf-8",
+		},
+	}
+	for _, tc := range tests {
+		got, cs, err := DecodeToUTF8(tc.raw, tc.contentType)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(got) != tc.want || cs != tc.wantCharset {
+			t.Errorf("want: %s (%s), got: %s (%s)", tc.want, tc.wantCharset, got, cs)
+		}
+	}
+}
+
+func TestGetRawAndDocCharset(t *testing.T) {
+	body := encode(t, simplifiedchinese.GBK, "<html><body><p>日经中文网</p></body></html>")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=gb2312")
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	_, doc, cs, err := GetRawAndDocCharset(u, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs != "gbk" {
+		t.Errorf("want gbk, got: %s", cs)
+	}
+	ps := ElementsByTag(doc, "p")
+	if len(ps) != 1 || ps[0].FirstChild.Data != "日经中文网" {
+		t.Errorf("want transcoded <p>, got: %v", ps)
+	}
+}
@@ -0,0 +1,95 @@
+package exhtml
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ClassMatcher matches elements by the tokens of their space separated
+// class attribute, so that "article-photo" matches
+// <div class="article-photo wide">.
+type ClassMatcher struct {
+	// Classes are the class tokens looked for.
+	Classes []string
+	// All requires every class of Classes, otherwise any one is enough.
+	All bool
+	// IgnoreCase compares the classes ignoring case.
+	IgnoreCase bool
+}
+
+// AnyClass returns a ClassMatcher matching elements with any of classes.
+func AnyClass(classes ...string) ClassMatcher {
+	return ClassMatcher{Classes: classes}
+}
+
+// AllClasses returns a ClassMatcher matching elements with all of classes.
+func AllClasses(classes ...string) ClassMatcher {
+	return ClassMatcher{Classes: classes, All: true}
+}
+
+// Match reports whether the element n has the classes of m. A matcher
+// without classes matches nothing.
+func (m ClassMatcher) Match(n *html.Node) bool {
+	if n == nil || n.Type != html.ElementNode || len(m.Classes) == 0 {
+		return false
+	}
+	tokens := strings.Fields(attr(n, "class"))
+	has := func(class string) bool {
+		for _, t := range tokens {
+			if t == class || m.IgnoreCase && strings.EqualFold(t, class) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, c := range m.Classes {
+		if has(c) != m.All {
+			return !m.All
+		}
+	}
+	return m.All
+}
+
+// HasClass reports whether the element n has class among the tokens of its
+// class attribute.
+func HasClass(n *html.Node, class string) bool {
+	return AnyClass(class).Match(n)
+}
+
+// ElementsByTagAndClassToken is like ElementsByTagAndClass but matches
+// class against each token of the class attribute.
+func ElementsByTagAndClassToken(doc *html.Node, tag, class string) []*html.Node {
+	if class == "" {
+		return nil
+	}
+	return ElementsByTagAndClasses(doc, tag, AnyClass(class))
+}
+
+// ElementsByTagAndClasses returns the elements of doc named tag, or of any
+// name if tag is "", matched by m.
+func ElementsByTagAndClasses(doc *html.Node, tag string, m ClassMatcher) []*html.Node {
+	return FindAll(doc, tagAndClasses(tag, m))
+}
+
+func tagAndClasses(tag string, m ClassMatcher) Matcher {
+	if tag == "" {
+		return m.Match
+	}
+	return And(Tag(tag), m.Match)
+}
+
+// ElementsRmByTagClassToken is like ElementsRmByTagClass but matches class
+// against each token of the class attribute.
+func ElementsRmByTagClassToken(doc *html.Node, tag, class string) {
+	if class == "" {
+		return
+	}
+	ElementsRmByTagClasses(doc, tag, AnyClass(class))
+}
+
+// ElementsRmByTagClasses removes the descendants of doc named tag, or of
+// any name if tag is "", matched by m.
+func ElementsRmByTagClasses(doc *html.Node, tag string, m ClassMatcher) {
+	RemoveAll(doc, tagAndClasses(tag, m))
+}
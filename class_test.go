@@ -0,0 +1,74 @@
+package exhtml
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+var testClassDoc = `<html><body>
+<div class="content article-body">
+  <div class="article-photo wide" id="d1"><img class="cms-photo"></div>
+  <div class="article-photo" id="d2"></div>
+  <div class="Article-Photo Wide" id="d3"></div>
+  <p class="article-photo" id="p1"></p>
+</div>
+</body></html>`
+
+func classIDs(nodes []*html.Node) string {
+	var ids []string
+	for _, n := range nodes {
+		ids = append(ids, attr(n, "id"))
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestElementsByTagAndClasses(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testClassDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		tag  string
+		m    ClassMatcher
+		want string
+	}{
+		{"div", AnyClass("article-photo"), "d1 d2"},
+		{"", AnyClass("article-photo"), "d1 d2 p1"},
+		{"div", AllClasses("article-photo", "wide"), "d1"},
+		{"div", ClassMatcher{Classes: []string{"article-photo", "wide"}, All: true, IgnoreCase: true}, "d1 d3"},
+		{"div", AnyClass("wide", "missing"), "d1"},
+		{"div", ClassMatcher{}, ""},
+	}
+	for _, tc := range tcs {
+		if got := classIDs(ElementsByTagAndClasses(doc, tc.tag, tc.m)); got != tc.want {
+			t.Errorf("%s %+v: want %q, got %q", tc.tag, tc.m, tc.want, got)
+		}
+	}
+	if got := classIDs(ElementsByTagAndClassToken(doc, "div", "article-photo")); got != "d1 d2" {
+		t.Errorf("want d1 d2, got %q", got)
+	}
+	// the existing function still compares the whole attribute
+	if got := classIDs(ElementsByTagAndClass(doc, "div", "article-photo")); got != "d2" {
+		t.Errorf("want d2, got %q", got)
+	}
+}
+
+func TestElementsRmByTagClasses(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testClassDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ElementsRmByTagClassToken(doc, "div", "article-photo")
+	if got := classIDs(ElementsByTagAndClasses(doc, "", AnyClass("article-photo"))); got != "p1" {
+		t.Errorf("want p1 left, got %q", got)
+	}
+	if ElementsByTagAndClassToken(doc, "img", "cms-photo") != nil {
+		t.Errorf("want the img removed with its parent")
+	}
+	ElementsRmByTagClasses(doc, "", ClassMatcher{Classes: []string{"wide"}, IgnoreCase: true})
+	if got := classIDs(ElementsByTag(doc, "div", "p")); got != " p1" {
+		t.Errorf("want the outer div and p1 left, got %q", got)
+	}
+}
@@ -0,0 +1,234 @@
+package exhtml
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// DefaultCrawlDepth and DefaultCrawlWorkers are the MaxDepth and Workers
+// of a new Crawler.
+const (
+	DefaultCrawlDepth   = 2
+	DefaultCrawlWorkers = 4
+)
+
+// CrawlFunc is called by a Crawler with every page it fetched, its body
+// transcoded to UTF-8 and its parsed document. It may be called
+// concurrently.
+type CrawlFunc func(pageURL string, raw []byte, doc *html.Node)
+
+// CrawlTarget is a page of the frontier of a Crawler.
+type CrawlTarget struct {
+	URL string
+	// Depth is the number of links followed from the seed.
+	Depth int
+	// Seed is the seed URL the link scope is relative to.
+	Seed string
+}
+
+// CrawlState is the progress of a Crawler, to resume it later with
+// Crawler.Resume. It can be saved as JSON.
+type CrawlState struct {
+	Frontier []CrawlTarget
+	Seen     []string
+	Pages    int
+}
+
+// Crawler crawls the pages linked from seed URLs, breadth first.
+type Crawler struct {
+	// MaxDepth is the number of links followed from a seed, 0 crawls the
+	// seeds only and a negative value follows links without limit.
+	MaxDepth int
+	// MaxPages limits the pages fetched, 0 means no limit.
+	MaxPages int
+	// Workers is the number of pages fetched concurrently.
+	Workers int
+	// Links filters the links followed, scoped to the host of the seed
+	// they were found from. Normalize avoids crawling a page twice under
+	// different URLs.
+	Links LinkOptions
+	// OnError is called with the pages that could not be fetched or
+	// parsed. It may be called concurrently.
+	OnError func(pageURL string, err error)
+
+	fetcher *Fetcher
+	fn      CrawlFunc
+
+	mu       sync.Mutex
+	frontier []CrawlTarget
+	seen     map[string]bool
+	pages    int
+	running  bool
+	stopped  bool
+}
+
+// NewCrawler returns a Crawler fetching with f, or the default fetcher if f
+// is nil, and calling fn with every page.
+func NewCrawler(f *Fetcher, fn CrawlFunc) *Crawler {
+	if f == nil {
+		f = defaultFetcher
+	}
+	return &Crawler{
+		MaxDepth: DefaultCrawlDepth,
+		Workers:  DefaultCrawlWorkers,
+		fetcher:  f,
+		fn:       fn,
+		seen:     map[string]bool{},
+	}
+}
+
+// Add adds seed URLs to the frontier. It may be called while the crawler
+// runs.
+func (c *Crawler) Add(seeds ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range seeds {
+		u, err := url.Parse(s)
+		if err != nil {
+			return errors.WithMessage(err, "exhtml: Crawler.Add")
+		}
+		if c.Links.Normalize {
+			u = NormalizeURL(u)
+		}
+		c.push(CrawlTarget{URL: u.String(), Seed: u.String()})
+	}
+	return nil
+}
+
+// push adds t to the frontier if it was never seen. c.mu must be held.
+func (c *Crawler) push(t CrawlTarget) {
+	if c.seen[t.URL] {
+		return
+	}
+	c.seen[t.URL] = true
+	c.frontier = append(c.frontier, t)
+}
+
+// Run crawls until the frontier is empty, MaxPages pages were fetched,
+// Stop is called or ctx is done, and returns ctx.Err() in the latter case.
+// The pages being fetched are finished first, except for ctx, which
+// cancels them and puts them back in the frontier. Run can be called again
+// to resume the crawl.
+func (c *Crawler) Run(ctx context.Context) error {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return errors.New("exhtml: Crawler.Run: already running")
+	}
+	c.running, c.stopped = true, false
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.running = false
+		c.mu.Unlock()
+	}()
+
+	workers := c.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	done := make(chan struct{})
+	inFlight := 0
+	for {
+		c.mu.Lock()
+		for inFlight < workers && len(c.frontier) > 0 && !c.stopped && ctx.Err() == nil &&
+			(c.MaxPages <= 0 || c.pages < c.MaxPages) {
+			t := c.frontier[0]
+			c.frontier = c.frontier[1:]
+			c.pages++
+			inFlight++
+			go func() {
+				c.visit(ctx, t)
+				done <- struct{}{}
+			}()
+		}
+		c.mu.Unlock()
+		if inFlight == 0 {
+			return ctx.Err()
+		}
+		select {
+		case <-done:
+			inFlight--
+		case <-ctx.Done():
+			for ; inFlight > 0; inFlight-- {
+				<-done
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// Stop makes Run return once the pages being fetched are done.
+func (c *Crawler) Stop() {
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+}
+
+// State returns the progress of the crawl.
+func (c *Crawler) State() CrawlState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := CrawlState{
+		Frontier: append([]CrawlTarget(nil), c.frontier...),
+		Pages:    c.pages,
+	}
+	for u := range c.seen {
+		s.Seen = append(s.Seen, u)
+	}
+	return s
+}
+
+// Resume restores the progress of a crawl saved with State. It must not be
+// called while the crawler runs.
+func (c *Crawler) Resume(s CrawlState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frontier = append([]CrawlTarget(nil), s.Frontier...)
+	c.pages = s.Pages
+	c.seen = map[string]bool{}
+	for _, u := range s.Seen {
+		c.seen[u] = true
+	}
+	for _, t := range s.Frontier {
+		c.seen[t.URL] = true
+	}
+}
+
+func (c *Crawler) visit(ctx context.Context, t CrawlTarget) {
+	raw, doc, resp, err := c.fetcher.getDoc(ctx, t.URL)
+	if ctx.Err() != nil {
+		// put it back for a resumed crawl
+		c.mu.Lock()
+		c.frontier = append([]CrawlTarget{t}, c.frontier...)
+		c.pages--
+		c.mu.Unlock()
+		return
+	}
+	if err != nil {
+		if c.OnError != nil {
+			c.OnError(t.URL, err)
+		}
+		return
+	}
+	if c.fn != nil {
+		c.fn(t.URL, raw, doc)
+	}
+	if c.MaxDepth >= 0 && t.Depth >= c.MaxDepth {
+		return
+	}
+	seed, err := url.Parse(t.Seed)
+	if err != nil {
+		return
+	}
+	links := linksWithOptions(doc, resp.Request.URL, seed, c.Links)
+	c.mu.Lock()
+	for _, l := range links {
+		c.push(CrawlTarget{URL: l, Depth: t.Depth + 1, Seed: t.Seed})
+	}
+	c.mu.Unlock()
+}
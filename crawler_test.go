@@ -0,0 +1,141 @@
+package exhtml
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// newCrawlSite serves pages /0 to /9, each linking to the next two, an
+// external site and a missing page.
+func newCrawlSite() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var i int
+		if _, err := fmt.Sscanf(r.URL.Path, "/%d", &i); err != nil || i > 9 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `<html><body><h1>%d</h1>
+<a href="/%d">next</a> <a href="%d#top">skip</a>
+<a href="https://www.bbc.com/">bbc</a> <a href="/missing">missing</a>
+</body></html>`, i, i+1, i+2)
+	}))
+}
+
+type crawlLog struct {
+	mu    sync.Mutex
+	pages []string
+}
+
+func (l *crawlLog) visit(pageURL string, raw []byte, doc *html.Node) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pages = append(l.pages, pageURL[strings.LastIndex(pageURL, "/"):])
+}
+
+func (l *crawlLog) sorted() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ps := append([]string(nil), l.pages...)
+	sort.Strings(ps)
+	return strings.Join(ps, " ")
+}
+
+func TestCrawler(t *testing.T) {
+	ts := newCrawlSite()
+	defer ts.Close()
+
+	var log crawlLog
+	var errs []string
+	var mu sync.Mutex
+	c := NewCrawler(nil, log.visit)
+	c.Links.Normalize = true
+	c.OnError = func(pageURL string, err error) {
+		mu.Lock()
+		errs = append(errs, pageURL)
+		mu.Unlock()
+	}
+	if err := c.Add(ts.URL + "/0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// depth 2 from /0 reaches /1 to /4
+	if got, want := log.sorted(), "/0 /1 /2 /3 /4"; got != want {
+		t.Errorf("want pages %s, got: %s", want, got)
+	}
+	if len(errs) != 1 || !strings.HasSuffix(errs[0], "/missing") {
+		t.Errorf("want an error for /missing, got: %v", errs)
+	}
+}
+
+func TestCrawlerMaxPagesAndResume(t *testing.T) {
+	ts := newCrawlSite()
+	defer ts.Close()
+
+	var log crawlLog
+	c := NewCrawler(nil, log.visit)
+	c.MaxDepth = -1
+	c.MaxPages = 3
+	c.Workers = 1
+	c.Links.Normalize = true
+	c.Add(ts.URL + "/0")
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := log.sorted(), "/0 /1 /2"; got != want {
+		t.Errorf("want pages %s, got: %s", want, got)
+	}
+
+	// resume with another crawler from the saved state
+	state := c.State()
+	c2 := NewCrawler(nil, log.visit)
+	c2.MaxDepth = -1
+	c2.MaxPages = 100
+	c2.Links.Normalize = true
+	c2.Resume(state)
+	if err := c2.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := log.sorted(), "/0 /1 /2 /3 /4 /5 /6 /7 /8 /9"; got != want {
+		t.Errorf("want pages %s, got: %s", want, got)
+	}
+}
+
+func TestCrawlerStop(t *testing.T) {
+	ts := newCrawlSite()
+	defer ts.Close()
+
+	var c *Crawler
+	var log crawlLog
+	c = NewCrawler(nil, func(pageURL string, raw []byte, doc *html.Node) {
+		log.visit(pageURL, raw, doc)
+		c.Stop()
+	})
+	c.MaxDepth = -1
+	c.Workers = 1
+	c.Add(ts.URL + "/0")
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.sorted(); got != "/0" {
+		t.Errorf("want /0 only, got: %s", got)
+	}
+	if s := c.State(); len(s.Frontier) == 0 || s.Pages != 1 {
+		t.Errorf("want a frontier left after stop, got: %+v", s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Run(ctx); err != context.Canceled {
+		t.Errorf("want context.Canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package exhtml
+
+import (
+	"context"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// FeedProbePaths are tried on the site root by DiscoverFeeds if a page
+// announces no feed.
+var FeedProbePaths = []string{
+	"/feed",
+	"/rss",
+	"/rss.xml",
+	"/atom.xml",
+	"/feed.xml",
+	"/index.xml",
+	"/feed.json",
+}
+
+// Ways a feed was discovered, see FeedLink.Source.
+const (
+	SourceLink   = "link"
+	SourceAnchor = "anchor"
+	SourceProbe  = "probe"
+)
+
+// FeedLink is a feed found on a page.
+type FeedLink struct {
+	URL string
+	// Type is "rss", "atom" or "json", guessed from the URL for anchors.
+	Type  string
+	Title string
+	// Source is SourceLink, SourceAnchor or SourceProbe.
+	Source string
+}
+
+var feedMediaTypes = map[string]string{
+	"application/rss+xml":   "rss",
+	"application/atom+xml":  "atom",
+	"application/feed+json": "json",
+}
+
+// DiscoverFeedsInNode returns the feeds doc announces with
+// <link rel="alternate"> and the anchors that look like feeds. Relative
+// URLs are resolved against <base href> or base.
+func DiscoverFeedsInNode(doc *html.Node, base *url.URL) []FeedLink {
+	if doc == nil {
+		return nil
+	}
+	base = baseOf(doc, base)
+	var feeds []FeedLink
+	seen := map[string]bool{}
+	add := func(href string, fl FeedLink) {
+		u, err := resolve(base, href)
+		if err != nil || seen[u] {
+			return
+		}
+		seen[u] = true
+		fl.URL = u
+		feeds = append(feeds, fl)
+	}
+	for _, n := range ElementsByTag(doc, "link") {
+		if !hasToken(attr(n, "rel"), "alternate") {
+			continue
+		}
+		mt, _, _ := mime.ParseMediaType(attr(n, "type"))
+		t, ok := feedMediaTypes[mt]
+		if !ok && mt == "application/json" {
+			// WordPress announces its REST API as plain JSON on every
+			// page, so it is a feed only if the URL looks like one
+			_, ok = feedLikeURL(attr(n, "href"), "")
+			t = "json"
+		}
+		if ok && attr(n, "href") != "" {
+			add(attr(n, "href"), FeedLink{Type: t, Title: attr(n, "title"), Source: SourceLink})
+		}
+	}
+	for _, n := range ElementsByTag(doc, "a") {
+		href := attr(n, "href")
+		if href == "" {
+			continue
+		}
+		if t, ok := feedLikeURL(href, nodeText(n)); ok {
+			add(href, FeedLink{Type: t, Title: strings.TrimSpace(nodeText(n)), Source: SourceAnchor})
+		}
+	}
+	return feeds
+}
+
+// feedLikeURL guesses whether an anchor points at a feed and its type.
+func feedLikeURL(href, text string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	p := strings.ToLower(u.Path)
+	base := path.Base(strings.TrimSuffix(p, "/"))
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	isFeed := false
+	switch {
+	case ext == ".rss" || ext == ".atom":
+		isFeed = true
+	case ext == ".xml" || ext == ".json" || ext == "":
+		isFeed = strings.Contains(name, "rss")
+		for _, w := range strings.FieldsFunc(name, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+		}) {
+			isFeed = isFeed || w == "feed" || w == "feeds" || w == "atom"
+		}
+	}
+	if !isFeed && ext != ".html" && ext != ".htm" {
+		isFeed = strings.EqualFold(strings.TrimSpace(text), "rss")
+	}
+	if !isFeed {
+		return "", false
+	}
+	switch {
+	case ext == ".json":
+		return "json", true
+	case strings.Contains(p, "atom"):
+		return "atom", true
+	}
+	return "rss", true
+}
+
+// DiscoverFeeds fetches pageURL and returns the feeds it links to.
+// If the page has none, FeedProbePaths are tried on its host.
+func (f *Fetcher) DiscoverFeeds(pageURL string) ([]FeedLink, error) {
+	return f.DiscoverFeedsContext(context.Background(), pageURL)
+}
+
+// DiscoverFeedsContext is like DiscoverFeeds with a cancelable ctx.
+func (f *Fetcher) DiscoverFeedsContext(ctx context.Context, pageURL string) ([]FeedLink, error) {
+	_, doc, resp, err := f.getDoc(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	feeds := DiscoverFeedsInNode(doc, resp.Request.URL)
+	if len(feeds) > 0 {
+		return feeds, nil
+	}
+	for _, p := range FeedProbePaths {
+		if ctx.Err() != nil {
+			return feeds, ctx.Err()
+		}
+		u, _ := resp.Request.URL.Parse(p)
+		raw, _, err := f.get(ctx, u.String())
+		if err != nil {
+			continue
+		}
+		feed, err := ParseFeed(raw)
+		if err != nil {
+			continue
+		}
+		feeds = append(feeds, FeedLink{URL: u.String(), Type: feed.Type, Title: feed.Title, Source: SourceProbe})
+	}
+	return feeds, nil
+}
+
+// DiscoverFeeds fetches pageURL and returns the feeds it links to.
+// If the page has none, FeedProbePaths are tried on its host.
+func DiscoverFeeds(pageURL string) ([]FeedLink, error) {
+	return defaultFetcher.DiscoverFeeds(pageURL)
+}
+
+// DiscoverFeedsContext is like DiscoverFeeds with a cancelable ctx.
+func DiscoverFeedsContext(ctx context.Context, pageURL string) ([]FeedLink, error) {
+	return defaultFetcher.DiscoverFeedsContext(ctx, pageURL)
+}
+
+// attr returns the value of attribute key of n.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasToken reports whether the space separated list s contains tok,
+// ignoring case.
+func hasToken(s, tok string) bool {
+	for _, f := range strings.Fields(s) {
+		if strings.EqualFold(f, tok) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeText returns the text of n and its descendants.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	ForEachNode(n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}, nil)
+	return b.String()
+}
+
+// baseOf returns the URL of the first <base href> of doc resolved against
+// u, or u if there is none.
+func baseOf(doc *html.Node, u *url.URL) *url.URL {
+	for _, n := range ElementsByTag(doc, "base") {
+		href := attr(n, "href")
+		if href == "" {
+			continue
+		}
+		resolved, err := url.Parse(href)
+		if u != nil {
+			resolved, err = u.Parse(href)
+		}
+		if err == nil {
+			return resolved
+		}
+	}
+	return u
+}
+
+// resolve returns href resolved against base, or href if base is nil.
+func resolve(base *url.URL, href string) (string, error) {
+	href = strings.TrimSpace(href)
+	if base == nil {
+		u, err := url.Parse(href)
+		if err != nil {
+			return "", err
+		}
+		return u.String(), nil
+	}
+	u, err := base.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
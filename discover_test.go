@@ -0,0 +1,100 @@
+package exhtml
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestDiscoverFeedsInNode(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><head>
+<base href="https://example.com/zh/">
+<link rel="alternate" type="application/rss+xml" title="新闻" href="rss/news.rss">
+<link rel="Alternate" type="application/atom+xml; charset=utf-8" href="/atom.xml">
+<link rel="alternate" type="application/feed+json" href="https://example.com/feed.json">
+<link rel="stylesheet" type="text/css" href="/style.css">
+<link rel="alternate" hreflang="en" href="/en/">
+</head><body>
+<a href="/rss/news.rss">duplicate</a>
+<a href="/news/feed/">Feed</a>
+<a href="/feedback">feedback</a>
+<a href="/subscribe">RSS</a>
+<a href="/article/1.html">article</a>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/zh/index.html")
+	got := DiscoverFeedsInNode(doc, base)
+	want := []FeedLink{
+		{"https://example.com/zh/rss/news.rss", "rss", "新闻", SourceLink},
+		{"https://example.com/atom.xml", "atom", "", SourceLink},
+		{"https://example.com/feed.json", "json", "", SourceLink},
+		{"https://example.com/rss/news.rss", "rss", "duplicate", SourceAnchor},
+		{"https://example.com/news/feed/", "rss", "Feed", SourceAnchor},
+		{"https://example.com/subscribe", "rss", "RSS", SourceAnchor},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d feeds, got: %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%d: want %+v, got: %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestDiscoverFeedsInNodeWordPress(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><head>
+<link rel="alternate" type="application/rss+xml" title="Blog &raquo; Feed" href="https://example.com/feed/">
+<link rel="alternate" type="application/json" title="JSON" href="https://example.com/wp-json/wp/v2/pages/2">
+<link rel="alternate" type="application/json+oembed" href="https://example.com/wp-json/oembed/1.0/embed?url=x">
+<link rel="alternate" type="application/json" href="https://example.com/feed.json">
+</head><body></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/about/")
+	got := DiscoverFeedsInNode(doc, base)
+	want := []FeedLink{
+		{"https://example.com/feed/", "rss", "Blog » Feed", SourceLink},
+		{"https://example.com/feed.json", "json", "", SourceLink},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d feeds, got: %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%d: want %+v, got: %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestDiscoverFeedsProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body><p>no feeds here</p></body></html>")
+	})
+	mux.HandleFunc("/atom.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title></feed>`)
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	got, err := DiscoverFeeds(ts.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].URL != ts.URL+"/atom.xml" || got[0].Type != "atom" ||
+		got[0].Title != "Atom" || got[0].Source != SourceProbe {
+		t.Errorf("want the probed atom feed, got: %+v", got)
+	}
+}
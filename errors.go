@@ -0,0 +1,95 @@
+package exhtml
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPStatusError is returned when a server answers with a status
+// other than 2xx.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("getting %s: %s", e.URL, e.Status)
+}
+
+func newHTTPStatusError(src string, resp *http.Response) *HTTPStatusError {
+	return &HTTPStatusError{
+		URL:        src,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+}
+
+// ParseError is returned when a body cannot be parsed as HTML or as a feed.
+type ParseError struct {
+	URL string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parsing %s: %v", e.URL, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// TooLargeError is returned when a body exceeds the max body size.
+type TooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("reading %s: body exceeds %d bytes", e.URL, e.Limit)
+}
+
+// TimeoutError is returned when a request or its context timed out.
+type TimeoutError struct {
+	URL string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("requesting %s: timeout: %v", e.URL, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports true, so TimeoutError satisfies net.Error checks.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// RobotsDisallowedError is returned when robots.txt disallows a URL.
+type RobotsDisallowedError struct {
+	URL string
+}
+
+func (e *RobotsDisallowedError) Error() string {
+	return fmt.Sprintf("requesting %s: disallowed by robots.txt", e.URL)
+}
+
+// requestError turns a timeout of err into a TimeoutError.
+func requestError(src string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return &TimeoutError{URL: src, Err: err}
+	}
+	return err
+}
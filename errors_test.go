@@ -0,0 +1,74 @@
+package exhtml
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPStatusError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Blocked", "1")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	_, _, err := GetRawAndDoc(u, time.Second)
+	var se *HTTPStatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("want *HTTPStatusError, got: %v", err)
+	}
+	if se.StatusCode != http.StatusForbidden || se.Header.Get("X-Blocked") != "1" {
+		t.Errorf("want 403 with headers, got: %d %v", se.StatusCode, se.Header)
+	}
+	if _, err := ExtractLinks(ts.URL); !errors.As(err, &se) {
+		t.Errorf("ExtractLinks: want *HTTPStatusError, got: %v", err)
+	}
+}
+
+func TestTooLargeError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("a", 100))
+	}))
+	defer ts.Close()
+
+	_, err := NewFetcher(WithMaxBodySize(10)).ExtractRss(ts.URL)
+	var te *TooLargeError
+	if !errors.As(err, &te) || te.Limit != 10 {
+		t.Errorf("want *TooLargeError with limit 10, got: %v", err)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "not a feed")
+	}))
+	defer ts.Close()
+
+	_, err := ExtractRssGuids(ts.URL)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.URL != ts.URL {
+		t.Errorf("want *ParseError, got: %v", err)
+	}
+}
+
+func TestTimeoutError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(WithTimeout(10*time.Millisecond), WithRetryPolicy(&BackoffPolicy{MaxAttempts: 1}))
+	u, _ := url.Parse(ts.URL)
+	_, _, err := f.GetRawAndDoc(u, time.Second)
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Errorf("want *TimeoutError, got: %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package exhtml
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/json"
+	"github.com/mmcdole/gofeed/rss"
+)
+
+// Feed is an RSS, Atom or JSON feed.
+type Feed struct {
+	// Type is "rss", "atom" or "json".
+	Type        string
+	Title       string
+	Description string
+	// Link is the website of the feed, FeedLink the feed itself.
+	Link      string
+	FeedLink  string
+	Language  string
+	Authors   []Person
+	Published time.Time
+	Updated   time.Time
+	// TTL, SkipHours and SkipDays are the RSS hints on when to poll,
+	// SkipHours are hours of the day in UTC.
+	TTL       time.Duration
+	SkipHours []int
+	SkipDays  []time.Weekday
+	Items     []*FeedItem
+}
+
+// FeedItem is an item of a Feed. Times are zero if the feed has none.
+type FeedItem struct {
+	GUID        string
+	Link        string
+	Title       string
+	Description string
+	// Content is content:encoded of RSS or content of Atom and JSON.
+	Content    string
+	Authors    []Person
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+	Enclosures []Enclosure
+}
+
+// Person is an author of a feed or an item.
+type Person struct {
+	Name  string
+	Email string
+}
+
+// Enclosure is a media file attached to an item.
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
+}
+
+// ParseFeed parses raw as RSS, Atom or JSON Feed 1.0 and 1.1.
+func ParseFeed(raw []byte) (*Feed, error) {
+	gf, err := gofeed.NewParser().Parse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	f := newFeed(gf)
+	if f.Type == "rss" {
+		// the universal feed drops the channel hints
+		if rf, err := (&rss.Parser{}).Parse(bytes.NewReader(raw)); err == nil {
+			setRssHints(f, rf)
+		}
+	}
+	if f.Type == "json" {
+		// the universal feed takes the duration for the enclosure length
+		if jf, err := (&json.Parser{}).Parse(bytes.NewReader(raw)); err == nil {
+			setJSONLengths(f, jf)
+		}
+	}
+	return f, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func setRssHints(f *Feed, rf *rss.Feed) {
+	if m, err := strconv.Atoi(strings.TrimSpace(rf.TTL)); err == nil && m > 0 {
+		f.TTL = time.Duration(m) * time.Minute
+	}
+	for _, h := range rf.SkipHours {
+		if n, err := strconv.Atoi(strings.TrimSpace(h)); err == nil && n >= 0 && n < 24 {
+			f.SkipHours = append(f.SkipHours, n)
+		}
+	}
+	for _, d := range rf.SkipDays {
+		if wd, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]; ok {
+			f.SkipDays = append(f.SkipDays, wd)
+		}
+	}
+}
+
+func setJSONLengths(f *Feed, jf *json.Feed) {
+	for i, it := range jf.Items {
+		if i >= len(f.Items) || it.Attachments == nil {
+			continue
+		}
+		encs := f.Items[i].Enclosures
+		for j, a := range *it.Attachments {
+			if j < len(encs) {
+				encs[j].Length = a.SizeInBytes
+			}
+		}
+	}
+}
+
+func newFeed(gf *gofeed.Feed) *Feed {
+	f := &Feed{
+		Type:        gf.FeedType,
+		Title:       gf.Title,
+		Description: gf.Description,
+		Link:        gf.Link,
+		FeedLink:    gf.FeedLink,
+		Language:    gf.Language,
+		Authors:     newPersons(gf.Author, gf.Authors),
+		Published:   timeOf(gf.PublishedParsed),
+		Updated:     timeOf(gf.UpdatedParsed),
+	}
+	for _, it := range gf.Items {
+		item := &FeedItem{
+			GUID:        it.GUID,
+			Link:        it.Link,
+			Title:       it.Title,
+			Description: it.Description,
+			Content:     it.Content,
+			Authors:     newPersons(it.Author, it.Authors),
+			Categories:  it.Categories,
+			Published:   timeOf(it.PublishedParsed),
+			Updated:     timeOf(it.UpdatedParsed),
+		}
+		for _, e := range it.Enclosures {
+			n, _ := strconv.ParseInt(e.Length, 10, 64)
+			item.Enclosures = append(item.Enclosures, Enclosure{URL: e.URL, Type: e.Type, Length: n})
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f
+}
+
+// newPersons merges the single and the list author fields of gofeed.
+func newPersons(p *gofeed.Person, ps []*gofeed.Person) []Person {
+	var persons []Person
+	seen := map[gofeed.Person]bool{}
+	for _, p := range append([]*gofeed.Person{p}, ps...) {
+		if p == nil || seen[*p] {
+			continue
+		}
+		seen[*p] = true
+		persons = append(persons, Person{Name: p.Name, Email: p.Email})
+	}
+	return persons
+}
+
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// ExtractFeed fetches and parses the feed at weburl.
+func (f *Fetcher) ExtractFeed(weburl string) (*Feed, error) {
+	return f.ExtractFeedContext(context.Background(), weburl)
+}
+
+// ExtractFeedContext is like ExtractFeed with a cancelable ctx.
+func (f *Fetcher) ExtractFeedContext(ctx context.Context, weburl string) (*Feed, error) {
+	raw, _, err := f.get(ctx, weburl)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := ParseFeed(raw)
+	if err != nil {
+		return nil, &ParseError{URL: weburl, Err: err}
+	}
+	return feed, nil
+}
+
+// ExtractFeed fetches and parses the feed at weburl.
+func ExtractFeed(weburl string) (*Feed, error) {
+	return defaultFetcher.ExtractFeed(weburl)
+}
+
+// ExtractFeedContext is like ExtractFeed with a cancelable ctx.
+func ExtractFeedContext(ctx context.Context, weburl string) (*Feed, error) {
+	return defaultFetcher.ExtractFeedContext(ctx, weburl)
+}
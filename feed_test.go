@@ -0,0 +1,129 @@
+package exhtml
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testRss = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+<title>越通社</title>
+<link>https://zh.vietnamplus.vn/</link>
+<description>越通社新闻</description>
+<language>zh-cn</language>
+<item>
+<title>越南与德国加强合作</title>
+<link>https://zh.vietnamplus.vn/143986.vnp</link>
+<guid isPermaLink="false">143986</guid>
+<description>摘要</description>
+<content:encoded><![CDATA[<p>全文</p>]]></content:encoded>
+<dc:creator>越通社</dc:creator>
+<category>时政</category>
+<category>外交</category>
+<pubDate>Tue, 10 Aug 2021 10:30:00 +0700</pubDate>
+<enclosure url="https://zh.vietnamplus.vn/143986.jpg" type="image/jpeg" length="1024"/>
+</item>
+</channel>
+</rss>`
+
+func TestExtractFeed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, testRss)
+	}))
+	defer ts.Close()
+
+	feed, err := ExtractFeed(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Type != "rss" || feed.Title != "越通社" || feed.Language != "zh-cn" || len(feed.Items) != 1 {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+	it := feed.Items[0]
+	want := time.Date(2021, 8, 10, 3, 30, 0, 0, time.UTC)
+	switch {
+	case it.GUID != "143986", it.Link != "https://zh.vietnamplus.vn/143986.vnp":
+		t.Errorf("want guid and link, got: %q %q", it.GUID, it.Link)
+	case it.Description != "摘要", it.Content != "<p>全文</p>":
+		t.Errorf("want description and content, got: %q %q", it.Description, it.Content)
+	case len(it.Authors) != 1 || it.Authors[0].Name != "越通社":
+		t.Errorf("want author 越通社, got: %v", it.Authors)
+	case len(it.Categories) != 2:
+		t.Errorf("want 2 categories, got: %v", it.Categories)
+	case !it.Published.Equal(want):
+		t.Errorf("want published %v, got: %v", want, it.Published)
+	case len(it.Enclosures) != 1 || it.Enclosures[0].Length != 1024 || it.Enclosures[0].Type != "image/jpeg":
+		t.Errorf("want 1 enclosure, got: %v", it.Enclosures)
+	}
+}
+
+func TestExtractFeedFixture(t *testing.T) {
+	feed, err := fixtures().ExtractFeed("https://china.kyodonews.net/rss/news.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range feed.Items {
+		if it.Title == "" || it.GUID == "" || it.Published.IsZero() {
+			t.Errorf("want title, guid and date, got: %+v", it)
+		}
+	}
+}
+
+func TestParseFeedHints(t *testing.T) {
+	feed, err := ParseFeed([]byte(`<rss version="2.0"><channel><title>t</title>
+<ttl>30</ttl>
+<skipHours><hour>0</hour><hour>23</hour></skipHours>
+<skipDays><day>Sunday</day></skipDays>
+</channel></rss>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.TTL != 30*time.Minute || len(feed.SkipHours) != 2 || feed.SkipHours[1] != 23 ||
+		len(feed.SkipDays) != 1 || feed.SkipDays[0] != time.Sunday {
+		t.Errorf("unexpected hints: %v %v %v", feed.TTL, feed.SkipHours, feed.SkipDays)
+	}
+}
+
+func TestParseJSONFeed(t *testing.T) {
+	feed, err := ParseFeed([]byte(`{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "越通社",
+  "home_page_url": "https://zh.vietnamplus.vn/",
+  "feed_url": "https://zh.vietnamplus.vn/feed.json",
+  "language": "zh-cn",
+  "authors": [{"name": "越通社"}],
+  "items": [{
+    "id": "143986",
+    "url": "https://zh.vietnamplus.vn/143986.vnp",
+    "title": "越南与德国加强合作",
+    "summary": "摘要",
+    "content_html": "<p>全文</p>",
+    "date_published": "2021-08-10T10:30:00+07:00",
+    "tags": ["时政"],
+    "attachments": [{"url": "https://zh.vietnamplus.vn/143986.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 1024, "duration_in_seconds": 60}]
+  }]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Type != "json" || feed.Language != "zh-cn" || len(feed.Authors) != 1 || len(feed.Items) != 1 {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+	it := feed.Items[0]
+	want := time.Date(2021, 8, 10, 3, 30, 0, 0, time.UTC)
+	switch {
+	case it.GUID != "143986", it.Link != "https://zh.vietnamplus.vn/143986.vnp":
+		t.Errorf("want guid and link, got: %q %q", it.GUID, it.Link)
+	case it.Description != "摘要", it.Content != "<p>全文</p>":
+		t.Errorf("want description and content, got: %q %q", it.Description, it.Content)
+	case !it.Published.Equal(want):
+		t.Errorf("want published %v, got: %v", want, it.Published)
+	case len(it.Enclosures) != 1 || it.Enclosures[0].Length != 1024:
+		t.Errorf("want 1 enclosure of 1024 bytes, got: %v", it.Enclosures)
+	}
+}
@@ -0,0 +1,379 @@
+package exhtml
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type rssDoc struct {
+	XMLName      xml.Name   `xml:"rss"`
+	Version      string     `xml:"version,attr"`
+	XMLNSContent string     `xml:"xmlns:content,attr"`
+	XMLNSDC      string     `xml:"xmlns:dc,attr"`
+	Channel      rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	Language      string     `xml:"language,omitempty"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	TTL           int        `xml:"ttl,omitempty"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title,omitempty"`
+	Link        string        `xml:"link,omitempty"`
+	GUID        *rssGUID      `xml:"guid"`
+	Description string        `xml:"description,omitempty"`
+	Content     *cdata        `xml:"content:encoded"`
+	Creators    []string      `xml:"dc:creator"`
+	Categories  []string      `xml:"category"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// WriteRSS writes feed to w as RSS 2.0.
+func WriteRSS(w io.Writer, feed *Feed) error {
+	doc := rssDoc{
+		Version:      "2.0",
+		XMLNSContent: "http://purl.org/rss/1.0/modules/content/",
+		XMLNSDC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       feed.Title,
+			Link:        feed.Link,
+			Description: feed.Description,
+			Language:    feed.Language,
+			TTL:         int(feed.TTL / time.Minute),
+		},
+	}
+	if t := feedUpdated(feed); !t.IsZero() {
+		doc.Channel.LastBuildDate = t.Format(time.RFC1123Z)
+	}
+	for _, it := range feed.Items {
+		ri := &rssItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			Description: it.Description,
+			Categories:  it.Categories,
+		}
+		if it.GUID != "" {
+			ri.GUID = &rssGUID{IsPermaLink: it.GUID == it.Link, Value: it.GUID}
+		}
+		if it.Content != "" {
+			ri.Content = &cdata{it.Content}
+		}
+		for _, p := range it.Authors {
+			ri.Creators = append(ri.Creators, p.Name)
+		}
+		if !it.Published.IsZero() {
+			ri.PubDate = it.Published.Format(time.RFC1123Z)
+		}
+		// RSS allows a single enclosure
+		if len(it.Enclosures) > 0 {
+			e := it.Enclosures[0]
+			ri.Enclosure = &rssEnclosure{URL: e.URL, Type: e.Type, Length: e.Length}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, ri)
+	}
+	return errors.WithMessage(writeXML(w, doc), "exhtml: WriteRSS")
+}
+
+type atomDoc struct {
+	XMLName  xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string       `xml:"xml:lang,attr,omitempty"`
+	Title    string       `xml:"title"`
+	Subtitle string       `xml:"subtitle,omitempty"`
+	ID       string       `xml:"id"`
+	Updated  string       `xml:"updated"`
+	Links    []atomLink   `xml:"link"`
+	Authors  []atomPerson `xml:"author"`
+	Entries  []*atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary"`
+	Content    *atomText      `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteAtom writes feed to w as Atom 1.0. Entries without an updated
+// time get their published time or the one of the feed. The feed is
+// identified by its FeedLink or Link and fails without both, entries
+// without a GUID or Link get an id derived from the feed id and their
+// content.
+func WriteAtom(w io.Writer, feed *Feed) error {
+	updated := feedUpdated(feed)
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	doc := atomDoc{
+		Lang:     feed.Language,
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		ID:       feed.FeedLink,
+		Updated:  updated.Format(time.RFC3339),
+		Authors:  atomPersons(feed.Authors),
+	}
+	if doc.ID == "" {
+		doc.ID = feed.Link
+	}
+	if doc.ID == "" {
+		return errors.New("exhtml: WriteAtom: feed has no FeedLink or Link for its id")
+	}
+	if feed.Link != "" {
+		doc.Links = append(doc.Links, atomLink{Href: feed.Link, Rel: "alternate"})
+	}
+	if feed.FeedLink != "" {
+		doc.Links = append(doc.Links, atomLink{Href: feed.FeedLink, Rel: "self"})
+	}
+	for _, it := range feed.Items {
+		e := &atomEntry{
+			Title:   it.Title,
+			ID:      it.GUID,
+			Authors: atomPersons(it.Authors),
+		}
+		if e.ID == "" {
+			e.ID = it.Link
+		}
+		if e.ID == "" {
+			e.ID = itemID(doc.ID, it)
+		}
+		switch {
+		case !it.Updated.IsZero():
+			e.Updated = it.Updated.Format(time.RFC3339)
+		case !it.Published.IsZero():
+			e.Updated = it.Published.Format(time.RFC3339)
+		default:
+			e.Updated = doc.Updated
+		}
+		if !it.Published.IsZero() {
+			e.Published = it.Published.Format(time.RFC3339)
+		}
+		if it.Link != "" {
+			e.Links = append(e.Links, atomLink{Href: it.Link, Rel: "alternate"})
+		}
+		for _, enc := range it.Enclosures {
+			e.Links = append(e.Links, atomLink{Href: enc.URL, Rel: "enclosure", Type: enc.Type, Length: enc.Length})
+		}
+		for _, c := range it.Categories {
+			e.Categories = append(e.Categories, atomCategory{Term: c})
+		}
+		if it.Description != "" {
+			e.Summary = &atomText{Type: "html", Value: it.Description}
+		}
+		if it.Content != "" {
+			e.Content = &atomText{Type: "html", Value: it.Content}
+		}
+		doc.Entries = append(doc.Entries, e)
+	}
+	return errors.WithMessage(writeXML(w, doc), "exhtml: WriteAtom")
+}
+
+// itemID returns a stable id for an item without GUID or link, the feed
+// id with a fragment hashing the item, or the hash alone without feed id.
+func itemID(feedID string, it *FeedItem) string {
+	h := sha1.New()
+	for _, s := range []string{it.Title, it.Published.Format(time.RFC3339), it.Description, it.Content} {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+	if feedID == "" {
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	return feedID + "#" + hex.EncodeToString(h.Sum(nil))
+}
+
+func atomPersons(ps []Person) []atomPerson {
+	var aps []atomPerson
+	for _, p := range ps {
+		aps = append(aps, atomPerson{Name: p.Name, Email: p.Email})
+	}
+	return aps
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []*jsonFeedItem  `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	Summary       string               `json:"summary,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	DateModified  string               `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+// WriteJSONFeed writes feed to w as JSON Feed 1.1. Item content is
+// written as content_html, items without a GUID are identified by their
+// link, or by an id derived from the feed URL and their content.
+func WriteJSONFeed(w io.Writer, feed *Feed) error {
+	jf := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: feed.Link,
+		FeedURL:     feed.FeedLink,
+		Description: feed.Description,
+		Language:    feed.Language,
+		Authors:     jsonFeedAuthors(feed.Authors),
+		Items:       []*jsonFeedItem{},
+	}
+	for _, it := range feed.Items {
+		ji := &jsonFeedItem{
+			ID:          it.GUID,
+			URL:         it.Link,
+			Title:       it.Title,
+			ContentHTML: it.Content,
+			Summary:     it.Description,
+			Authors:     jsonFeedAuthors(it.Authors),
+			Tags:        it.Categories,
+		}
+		if ji.ID == "" {
+			ji.ID = it.Link
+		}
+		if ji.ID == "" {
+			ji.ID = itemID(jsonFeedID(feed), it)
+		}
+		if !it.Published.IsZero() {
+			ji.DatePublished = it.Published.Format(time.RFC3339)
+		}
+		if !it.Updated.IsZero() {
+			ji.DateModified = it.Updated.Format(time.RFC3339)
+		}
+		for _, e := range it.Enclosures {
+			ji.Attachments = append(ji.Attachments, jsonFeedAttachment{URL: e.URL, MimeType: e.Type, SizeInBytes: e.Length})
+		}
+		jf.Items = append(jf.Items, ji)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return errors.WithMessage(enc.Encode(jf), "exhtml: WriteJSONFeed")
+}
+
+// jsonFeedID returns the URL identifying feed, its FeedLink or Link.
+func jsonFeedID(feed *Feed) string {
+	if feed.FeedLink != "" {
+		return feed.FeedLink
+	}
+	return feed.Link
+}
+
+func jsonFeedAuthors(ps []Person) []jsonFeedAuthor {
+	var as []jsonFeedAuthor
+	for _, p := range ps {
+		a := jsonFeedAuthor{Name: p.Name}
+		if p.Email != "" {
+			a.URL = "mailto:" + p.Email
+		}
+		as = append(as, a)
+	}
+	return as
+}
+
+// feedUpdated returns when feed was last updated: its Updated or
+// Published time, or the newest of its items.
+func feedUpdated(feed *Feed) time.Time {
+	if !feed.Updated.IsZero() {
+		return feed.Updated
+	}
+	if !feed.Published.IsZero() {
+		return feed.Published
+	}
+	var t time.Time
+	for _, it := range feed.Items {
+		for _, u := range []time.Time{it.Published, it.Updated} {
+			if u.After(t) {
+				t = u
+			}
+		}
+	}
+	return t
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
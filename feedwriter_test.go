@@ -0,0 +1,102 @@
+package exhtml
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriteFeed(t *testing.T) {
+	published := time.Date(2021, 8, 10, 3, 30, 0, 0, time.UTC)
+	feed := &Feed{
+		Title:       "越通社",
+		Description: "越通社新闻",
+		Link:        "https://zh.vietnamplus.vn/",
+		FeedLink:    "https://example.com/vietnamplus.xml",
+		Language:    "zh-cn",
+		Items: []*FeedItem{{
+			GUID:        "https://zh.vietnamplus.vn/143986.vnp",
+			Link:        "https://zh.vietnamplus.vn/143986.vnp",
+			Title:       "越南与德国加强合作 & more",
+			Description: "摘要",
+			Content:     "<p>全文</p>",
+			Authors:     []Person{{Name: "越通社"}},
+			Categories:  []string{"时政"},
+			Published:   published,
+			Enclosures:  []Enclosure{{URL: "https://zh.vietnamplus.vn/143986.jpg", Type: "image/jpeg", Length: 1024}},
+		}, {
+			Link:  "https://zh.vietnamplus.vn/143987.vnp",
+			Title: "无日期",
+		}},
+	}
+	for name, write := range map[string]func(io.Writer, *Feed) error{
+		"rss":  WriteRSS,
+		"atom": WriteAtom,
+		"json": WriteJSONFeed,
+	} {
+		var buf bytes.Buffer
+		if err := write(&buf, feed); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := ParseFeed(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, buf.String())
+		}
+		if got.Type != name || got.Title != feed.Title || got.Link != feed.Link || len(got.Items) != 2 {
+			t.Errorf("%s: unexpected feed: %+v", name, got)
+			continue
+		}
+		it := got.Items[0]
+		switch {
+		case it.GUID != feed.Items[0].GUID, it.Link != feed.Items[0].Link, it.Title != feed.Items[0].Title:
+			t.Errorf("%s: want guid, link and title, got: %q %q %q", name, it.GUID, it.Link, it.Title)
+		case it.Description != "摘要", it.Content != "<p>全文</p>":
+			t.Errorf("%s: want description and content, got: %q %q", name, it.Description, it.Content)
+		case len(it.Authors) != 1 || it.Authors[0].Name != "越通社":
+			t.Errorf("%s: want author, got: %v", name, it.Authors)
+		case len(it.Categories) != 1 || it.Categories[0] != "时政":
+			t.Errorf("%s: want category, got: %v", name, it.Categories)
+		case !it.Published.Equal(published):
+			t.Errorf("%s: want published %v, got: %v", name, published, it.Published)
+		case len(it.Enclosures) != 1 || it.Enclosures[0].Length != 1024:
+			t.Errorf("%s: want enclosure, got: %v", name, it.Enclosures)
+		}
+		if it := got.Items[1]; it.Link != feed.Items[1].Link || it.Title != "无日期" {
+			t.Errorf("%s: unexpected second item: %+v", name, it)
+		}
+	}
+}
+
+func TestWriteAtomID(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAtom(&buf, &Feed{Title: "no id"}); err == nil {
+		t.Errorf("want an error for a feed without id")
+	}
+
+	feed := &Feed{
+		Title:    "t",
+		FeedLink: "https://example.com/atom.xml",
+		Items:    []*FeedItem{{Title: "a"}, {Title: "b"}},
+	}
+	for name, write := range map[string]func(io.Writer, *Feed) error{
+		"atom": WriteAtom,
+		"json": WriteJSONFeed,
+	} {
+		ids := func() []string {
+			buf.Reset()
+			if err := write(&buf, feed); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseFeed(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return []string{got.Items[0].GUID, got.Items[1].GUID}
+		}
+		first, again := ids(), ids()
+		if first[0] == "" || first[0] == first[1] || first[0] != again[0] || first[1] != again[1] {
+			t.Errorf("%s: want distinct stable entry ids, got: %v then %v", name, first, again)
+		}
+	}
+}
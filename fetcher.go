@@ -0,0 +1,373 @@
+package exhtml
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// DefaultUserAgent is the User-Agent header sent when none is configured.
+const DefaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.61 Safari/537.36"
+
+// DefaultTimeout is the http.Client timeout of a Fetcher built without options.
+const DefaultTimeout = 10 * time.Second
+
+// Fetcher issues the HTTP requests behind the package level functions.
+// The zero value is not usable, build one with NewFetcher.
+type Fetcher struct {
+	client *http.Client
+	// transport and timeout are applied to client once every Option ran,
+	// so that WithClient does not drop them.
+	transport   http.RoundTripper
+	timeout     *time.Duration
+	header      http.Header
+	maxBodySize int64
+	// maxBodySizeSet is true if maxBodySize was set by WithMaxBodySize.
+	maxBodySizeSet bool
+	retryPolicy    RetryPolicy
+	detectCharset  bool
+	limiter        *HostLimiter
+	robots         *RobotsChecker
+	cache          Cache
+	contentTypes   []string
+}
+
+// Option configures a Fetcher.
+type Option func(*Fetcher)
+
+// WithClient uses a copy of c to perform requests. WithTransport and
+// WithTimeout apply to the copy whatever their order.
+func WithClient(c *http.Client) Option {
+	return func(f *Fetcher) {
+		if c == nil {
+			return
+		}
+		cc := *c
+		f.client = &cc
+	}
+}
+
+// WithTransport sets the http.RoundTripper used by the client.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(f *Fetcher) {
+		f.transport = rt
+	}
+}
+
+// WithTimeout sets the client timeout of every single request.
+func WithTimeout(d time.Duration) Option {
+	return func(f *Fetcher) {
+		f.timeout = &d
+	}
+}
+
+// WithHeader sets a header sent with every request.
+func WithHeader(key, value string) Option {
+	return func(f *Fetcher) {
+		f.header.Set(key, value)
+	}
+}
+
+// WithUserAgent overrides DefaultUserAgent.
+func WithUserAgent(ua string) Option {
+	return WithHeader("User-Agent", ua)
+}
+
+// WithMaxBodySize limits the bytes read from a response body to n instead of
+// DefaultMaxBodySize, n <= 0 means no limit.
+func WithMaxBodySize(n int64) Option {
+	return func(f *Fetcher) {
+		f.maxBodySize = n
+		f.maxBodySizeSet = true
+	}
+}
+
+// NewFetcher returns a Fetcher configured by opts.
+func NewFetcher(opts ...Option) *Fetcher {
+	f := &Fetcher{
+		client:        &http.Client{Timeout: DefaultTimeout},
+		header:        http.Header{},
+		maxBodySize:   DefaultMaxBodySize,
+		retryPolicy:   DefaultRetryPolicy,
+		detectCharset: true,
+	}
+	f.header.Set("User-Agent", DefaultUserAgent)
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.transport != nil {
+		f.client.Transport = f.transport
+	}
+	if f.timeout != nil {
+		f.client.Timeout = *f.timeout
+	}
+	if f.robots != nil {
+		f.robotsCheckRedirect()
+	}
+	return f
+}
+
+var defaultFetcher = NewFetcher()
+
+func (f *Fetcher) request(ctx context.Context, src string) (*http.Response, error) {
+	return f.requestHeader(ctx, src, nil)
+}
+
+// requestHeader is like request and sends h on top of the Fetcher headers.
+func (f *Fetcher) requestHeader(ctx context.Context, src string, h http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range f.header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	for k, vs := range h {
+		req.Header[k] = vs
+	}
+	if err := f.checkRobots(ctx, req); err != nil {
+		return nil, err
+	}
+	if f.limiter == nil {
+		return f.doCached(req)
+	}
+	release, err := f.limiter.Wait(ctx, req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.doCached(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// get requests src once and returns the body of a 2xx response.
+func (f *Fetcher) get(ctx context.Context, src string) ([]byte, *http.Response, error) {
+	return f.getLimit(ctx, src, f.maxBodySize)
+}
+
+// getLimit is like get with a max body size of limit, <= 0 means no limit.
+func (f *Fetcher) getLimit(ctx context.Context, src string, limit int64) ([]byte, *http.Response, error) {
+	resp, err := f.request(ctx, src)
+	if err != nil {
+		return nil, nil, requestError(src, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, resp, newHTTPStatusError(src, resp)
+	}
+	raw, err := f.readBodyLimit(src, resp, limit)
+	if err != nil {
+		return nil, resp, requestError(src, err)
+	}
+	return raw, resp, nil
+}
+
+// decode transcodes an HTML body to UTF-8 if charset detection is on.
+func (f *Fetcher) decode(src string, raw []byte, resp *http.Response) ([]byte, string, error) {
+	if !f.detectCharset {
+		return raw, "", nil
+	}
+	out, cs, err := DecodeToUTF8(raw, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, cs, &ParseError{URL: src, Err: err}
+	}
+	return out, cs, nil
+}
+
+// getDoc requests src once and parses the body of a 2xx response as HTML,
+// returning the body transcoded to UTF-8.
+func (f *Fetcher) getDoc(ctx context.Context, src string) ([]byte, *html.Node, *http.Response, error) {
+	raw, resp, err := f.get(ctx, src)
+	if err != nil {
+		return nil, nil, resp, err
+	}
+	raw, _, err = f.decode(src, raw, resp)
+	if err != nil {
+		return nil, nil, resp, err
+	}
+	doc, err := html.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, resp, &ParseError{URL: src, Err: err}
+	}
+	return raw, doc, resp, nil
+}
+
+// GetRawAndDoc can get html raw bytes and html.Node by rawurl.
+func (f *Fetcher) GetRawAndDoc(url *url.URL, retryTimeout time.Duration) ([]byte, *html.Node, error) {
+	return f.GetRawAndDocContext(context.Background(), url, retryTimeout)
+}
+
+// GetRawAndDocContext is like GetRawAndDoc but stops requesting and
+// retrying as soon as ctx is done.
+func (f *Fetcher) GetRawAndDocContext(ctx context.Context, url *url.URL, retryTimeout time.Duration) ([]byte, *html.Node, error) {
+	raw, doc, _, err := f.GetRawAndDocCharsetContext(ctx, url, retryTimeout)
+	return raw, doc, err
+}
+
+// GetRawAndDocCharset is like GetRawAndDoc and also returns the name of the
+// encoding the body was transcoded to UTF-8 from.
+func (f *Fetcher) GetRawAndDocCharset(url *url.URL, retryTimeout time.Duration) ([]byte, *html.Node, string, error) {
+	return f.GetRawAndDocCharsetContext(context.Background(), url, retryTimeout)
+}
+
+// GetRawAndDocCharsetContext is like GetRawAndDocCharset with a cancelable ctx.
+func (f *Fetcher) GetRawAndDocCharsetContext(ctx context.Context, url *url.URL, retryTimeout time.Duration) ([]byte, *html.Node, string, error) {
+	src := url.String()
+	// Get response form url
+	deadline := time.Now().Add(retryTimeout)
+	for attempt := 1; ; attempt++ {
+		resp, err := f.request(ctx, src)
+		if ctx.Err() != nil {
+			if err == nil {
+				resp.Body.Close()
+			}
+			return nil, nil, "", errors.WithMessage(requestError(src, ctx.Err()), "exhtml: GetRawAndDoc")
+		}
+		var rd *RobotsDisallowedError
+		if errors.As(err, &rd) || !f.retryPolicy.Retryable(resp, err) {
+			if err != nil {
+				return nil, nil, "", errors.WithMessage(requestError(src, err), "exhtml: GetRawAndDoc")
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return nil, nil, "", errors.WithMessage(newHTTPStatusError(src, resp), "exhtml: GetRawAndDoc")
+			}
+			raw, err := f.readBody(src, resp)
+			if err != nil {
+				return nil, nil, "", errors.WithMessage(
+					requestError(src, err), "exhtml: GetRawAndDoc: ReadAll",
+				)
+			}
+			raw, cs, err := f.decode(src, raw, resp)
+			if err != nil {
+				return nil, nil, "", errors.WithMessage(err, "exhtml: GetRawAndDoc")
+			}
+			doc, err := html.Parse(bytes.NewBuffer(raw))
+			if err != nil {
+				return nil, nil, "", errors.WithMessage(&ParseError{URL: src, Err: err}, "exhtml: GetRawAndDoc")
+			}
+			return raw, doc, cs, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = newHTTPStatusError(src, resp)
+		} else {
+			err = requestError(src, err)
+		}
+		delay, ok := f.retryPolicy.Backoff(attempt, resp)
+		if !ok || time.Now().Add(delay).After(deadline) {
+			return nil, nil, "", &ErrRetriesExhausted{Attempts: attempt, LastErr: err}
+		}
+		log.SetPrefix("[wait]")
+		log.SetFlags(0)
+		log.Printf("server not responding (%s); retrying in %v...", err, delay)
+		log.SetPrefix("")
+		log.SetFlags(3)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, nil, "", errors.WithMessage(requestError(src, err), "exhtml: GetRawAndDoc")
+		}
+	}
+}
+
+// ExtractRssGuids get value from <guid>
+func (f *Fetcher) ExtractRssGuids(weburl string) ([]string, error) {
+	return f.ExtractRssGuidsContext(context.Background(), weburl)
+}
+
+// ExtractRssGuidsContext is like ExtractRssGuids with a cancelable ctx.
+func (f *Fetcher) ExtractRssGuidsContext(ctx context.Context, weburl string) ([]string, error) {
+	feed, err := f.ExtractFeedContext(ctx, weburl)
+	if err != nil {
+		return nil, err
+	}
+	ls := []string{}
+	for _, e := range feed.Items {
+		ls = append(ls, e.GUID)
+	}
+	return ls, nil
+}
+
+// ExtractRss get value from <link> of every feed item.
+func (f *Fetcher) ExtractRss(weburl string) ([]string, error) {
+	return f.ExtractRssContext(context.Background(), weburl)
+}
+
+// ExtractRssContext is like ExtractRss with a cancelable ctx.
+func (f *Fetcher) ExtractRssContext(ctx context.Context, weburl string) ([]string, error) {
+	feed, err := f.ExtractFeedContext(ctx, weburl)
+	if err != nil {
+		return nil, err
+	}
+	ls := []string{}
+	for _, e := range feed.Items {
+		ls = append(ls, e.Link)
+	}
+	return ls, nil
+}
+
+// ExtractLinks makes an HTTP GET request to the specified URL, parses
+// the response as HTML, and returns the links in the HTML document.
+func (f *Fetcher) ExtractLinks(weburl string) ([]string, error) {
+	return f.ExtractLinksContext(context.Background(), weburl)
+}
+
+// ExtractLinksContext is like ExtractLinks with a cancelable ctx.
+func (f *Fetcher) ExtractLinksContext(ctx context.Context, weburl string) ([]string, error) {
+	u, err := url.Parse(weburl)
+	if err != nil {
+		return nil, err
+	}
+	_, doc, resp, err := f.getDoc(ctx, weburl)
+	if err != nil {
+		return nil, err
+	}
+	var links []string
+	visitNode := func(n *html.Node) {
+		// TODO: compress layers
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, a := range n.Attr {
+				if a.Key != "href" {
+					continue
+				}
+				link, err := resp.Request.URL.Parse(a.Val)
+				if err != nil {
+					continue // ignore bad URLs
+				}
+				// append only the target website
+				if strings.HasPrefix(a.Val, "http") && strings.Contains(a.Val, u.Hostname()) {
+					links = append(links, link.String())
+				} else if strings.HasPrefix(a.Val, "/") {
+					links = append(links, link.String())
+				}
+
+			}
+		}
+	}
+	ForEachNode(doc, visitNode, nil)
+	return links, nil
+}
+
+// sleepContext pauses for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package exhtml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetcherOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body><p>%s|%s</p></body></html>",
+			r.Header.Get("User-Agent"), r.Header.Get("X-Test"))
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(WithUserAgent("exhtml-test"), WithHeader("X-Test", "yes"))
+	u, _ := url.Parse(ts.URL)
+	raw, doc, err := f.GetRawAndDoc(u, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "exhtml-test|yes") {
+		t.Errorf("headers not sent, got: %s", raw)
+	}
+	if ps := ElementsByTag(doc, "p"); len(ps) != 1 {
+		t.Errorf("want 1 <p>, got: %d", len(ps))
+	}
+}
+
+func TestFetcherMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("a", 100))
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	if _, _, err := NewFetcher(WithMaxBodySize(10)).GetRawAndDoc(u, 5*time.Second); err == nil {
+		t.Error("want error for body over the limit, got nil")
+	}
+	if _, _, err := NewFetcher(WithMaxBodySize(100)).GetRawAndDoc(u, 5*time.Second); err != nil {
+		t.Errorf("want nil for body at the limit, got: %v", err)
+	}
+}
+
+func TestWithClientCopies(t *testing.T) {
+	c := &http.Client{Timeout: time.Minute}
+	f := NewFetcher(WithClient(c), WithTimeout(time.Second))
+	if c.Timeout != time.Minute {
+		t.Errorf("WithTimeout modified the caller's client")
+	}
+	if f.client.Timeout != time.Second {
+		t.Errorf("want timeout 1s, got: %v", f.client.Timeout)
+	}
+}
+
+func TestWithClientOptionOrder(t *testing.T) {
+	rt := &http.Transport{}
+	f := NewFetcher(WithTransport(rt), WithTimeout(time.Second), WithClient(&http.Client{}))
+	if f.client.Transport != rt {
+		t.Errorf("WithClient dropped the transport of WithTransport")
+	}
+	if f.client.Timeout != time.Second {
+		t.Errorf("want timeout 1s, got: %v", f.client.Timeout)
+	}
+}
+
+func TestGetRawAndDocContextCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<p>never read</p>")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	u, _ := url.Parse(ts.URL)
+	start := time.Now()
+	_, _, err := GetRawAndDocContext(ctx, u, time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("back-off was not aborted, took %v", time.Since(start))
+	}
+}
@@ -3,7 +3,8 @@ module github.com/hi20160616/exhtml
 go 1.15
 
 require (
-	github.com/mmcdole/gofeed v1.1.3 // indirect
+	github.com/mmcdole/gofeed v1.1.3
 	github.com/pkg/errors v0.9.1
 	golang.org/x/net v0.0.0-20210119194325-5f4716e94777
+	golang.org/x/text v0.3.3
 )
@@ -2,137 +2,64 @@
 package exhtml
 
 import (
-	"bytes"
-	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
-	"net/http"
+	"context"
 	"net/url"
-	"strings"
 	"time"
 
-	"github.com/mmcdole/gofeed"
-	"github.com/pkg/errors"
 	"golang.org/x/net/html"
 )
 
-func request(src string) (*http.Response, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", src, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.61 Safari/537.36")
-	return client.Do(req)
-}
-
 // GetRawAndDoc can get html raw bytes and html.Node by rawurl.
 func GetRawAndDoc(url *url.URL, retryTimeout time.Duration) ([]byte, *html.Node, error) {
-	// Get response form url
-	deadline := time.Now().Add(retryTimeout)
-	for tries := 0; time.Now().Before(deadline); tries++ {
-		resp, err := request(url.String())
-		if err == nil { // success
-			defer resp.Body.Close()
-			raw, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return nil, nil, errors.WithMessage(
-					err, "exhtml: GetRawAndDoc: ReadAll",
-				)
-			}
-			doc, err := html.Parse(bytes.NewBuffer(raw))
-			return raw, doc, nil
-		}
-		log.SetPrefix("[wait]")
-		log.SetFlags(0)
-		log.Printf("server not responding (%s); retrying...", err)
-		log.SetPrefix("")
-		log.SetFlags(3)
-		time.Sleep(time.Second << uint(tries)) // exponential back-off
-	}
-	return nil, nil, nil
+	return defaultFetcher.GetRawAndDoc(url, retryTimeout)
+}
+
+// GetRawAndDocContext is like GetRawAndDoc but stops requesting and
+// retrying as soon as ctx is done.
+func GetRawAndDocContext(ctx context.Context, url *url.URL, retryTimeout time.Duration) ([]byte, *html.Node, error) {
+	return defaultFetcher.GetRawAndDocContext(ctx, url, retryTimeout)
+}
+
+// GetRawAndDocCharset is like GetRawAndDoc and also returns the name of the
+// encoding the body was transcoded to UTF-8 from.
+func GetRawAndDocCharset(url *url.URL, retryTimeout time.Duration) ([]byte, *html.Node, string, error) {
+	return defaultFetcher.GetRawAndDocCharset(url, retryTimeout)
+}
+
+// GetRawAndDocCharsetContext is like GetRawAndDocCharset with a cancelable ctx.
+func GetRawAndDocCharsetContext(ctx context.Context, url *url.URL, retryTimeout time.Duration) ([]byte, *html.Node, string, error) {
+	return defaultFetcher.GetRawAndDocCharsetContext(ctx, url, retryTimeout)
 }
 
 // ExtractRssGuids get value from <guid>
 func ExtractRssGuids(weburl string) ([]string, error) {
-	resp, err := request(weburl)
-	if err != nil {
-		return nil, err
-	}
-	gf := gofeed.NewParser()
-	feed, err := gf.Parse(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	ls := []string{}
-	for _, e := range feed.Items {
-		ls = append(ls, e.GUID)
-	}
-	return ls, nil
+	return defaultFetcher.ExtractRssGuids(weburl)
+}
+
+// ExtractRssGuidsContext is like ExtractRssGuids with a cancelable ctx.
+func ExtractRssGuidsContext(ctx context.Context, weburl string) ([]string, error) {
+	return defaultFetcher.ExtractRssGuidsContext(ctx, weburl)
 }
 
+// ExtractRss get value from <link> of every feed item.
 func ExtractRss(weburl string) ([]string, error) {
-	resp, err := request(weburl)
-	if err != nil {
-		return nil, err
-	}
-	gf := gofeed.NewParser()
-	feed, err := gf.Parse(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	ls := []string{}
-	for _, e := range feed.Items {
-		ls = append(ls, e.Link)
-	}
-	return ls, nil
+	return defaultFetcher.ExtractRss(weburl)
+}
+
+// ExtractRssContext is like ExtractRss with a cancelable ctx.
+func ExtractRssContext(ctx context.Context, weburl string) ([]string, error) {
+	return defaultFetcher.ExtractRssContext(ctx, weburl)
 }
 
 // ExtractLinks makes an HTTP GET request to the specified URL, parses
 // the response as HTML, and returns the links in the HTML document.
 func ExtractLinks(weburl string) ([]string, error) {
-	u, err := url.Parse(weburl)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := request(weburl)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("getting %s: %s", weburl, resp.Status)
-	}
-	doc, err := html.Parse(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return nil, fmt.Errorf("parsing %s as HTML: %v", weburl, err)
-	}
-	var links []string
-	visitNode := func(n *html.Node) {
-		// TODO: compress layers
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, a := range n.Attr {
-				if a.Key != "href" {
-					continue
-				}
-				link, err := resp.Request.URL.Parse(a.Val)
-				if err != nil {
-					continue // ignore bad URLs
-				}
-				// append only the target website
-				if strings.HasPrefix(a.Val, "http") && strings.Contains(a.Val, u.Hostname()) {
-					links = append(links, link.String())
-				} else if strings.HasPrefix(a.Val, "/") {
-					links = append(links, link.String())
-				}
+	return defaultFetcher.ExtractLinks(weburl)
+}
 
-			}
-		}
-	}
-	ForEachNode(doc, visitNode, nil)
-	return links, nil
+// ExtractLinksContext is like ExtractLinks with a cancelable ctx.
+func ExtractLinksContext(ctx context.Context, weburl string) ([]string, error) {
+	return defaultFetcher.ExtractLinksContext(ctx, weburl)
 }
 
 func ForEachNode(n *html.Node, pre, post func(n *html.Node)) {
@@ -197,29 +124,13 @@ func DivWithAttr(doc *html.Node, attrName, attrValue string) []*html.Node {
 	return nodes
 }
 
+// DivWithAttr2 returns the raw bytes of the first div of raw with the
+// attribute attrName valued attrValue, up to its end tag.
 func DivWithAttr2(raw []byte, attrName, attrValue string) []byte {
 	if attrName == "" || attrValue == "" || raw == nil {
 		return nil
 	}
-	z := html.NewTokenizer(bytes.NewReader(raw))
-	for {
-		tt := z.Next()
-		t := z.Token()
-		if err := z.Err(); err != nil && err == io.EOF {
-			break
-		}
-		switch tt {
-		case html.StartTagToken:
-			if "div" == t.Data {
-				for _, a := range t.Attr {
-					if a.Key == attrName && a.Val == attrValue {
-						return z.Buffered()
-					}
-				}
-			}
-		}
-	}
-	return nil
+	return extractFirstElement(raw, And(Tag("div"), Attr(attrName, attrValue)))
 }
 
 func ElementsNext(doc *html.Node) []*html.Node {
@@ -360,40 +271,13 @@ func ElementsByTag(doc *html.Node, name ...string) []*html.Node {
 	return nodes
 }
 
+// ElementsByTag2 returns the raw bytes of the elements of raw named any of
+// tags, each up to its end tag, one after another.
 func ElementsByTag2(raw []byte, tags ...string) []byte {
 	if raw == nil || tags == nil {
 		return nil
 	}
-	z := html.NewTokenizer(bytes.NewReader(raw))
-	var b bytes.Buffer
-	depth := 0
-	for {
-		tt := z.Next()
-		if err := z.Err(); err == io.EOF {
-			break
-		}
-		switch tt {
-		case html.ErrorToken:
-			return []byte(z.Err().Error())
-		case html.TextToken:
-			if depth > 0 {
-				b.Write(z.Text())
-			}
-		case html.StartTagToken, html.EndTagToken:
-			tn, _ := z.TagName()
-			for _, tag := range tags {
-				if string(tn) == tag {
-					b.Write(z.Text())
-				}
-			}
-			if tt == html.StartTagToken {
-				depth++
-			} else {
-				depth--
-			}
-		}
-	}
-	return b.Bytes()
+	return joinElements(raw, Tag(tags...))
 }
 
 func TagWithAttr(doc *html.Node, tag, attr string) []*html.Node {
@@ -436,30 +320,14 @@ func ElementsByTagAndClass(doc *html.Node, tag, class string) []*html.Node {
 	return nodes
 }
 
+// ElementsByTagAndClass2 returns the raw bytes of the elements of raw named
+// tag with the class attribute class, each up to its end tag, one after
+// another.
 func ElementsByTagAndClass2(raw []byte, tag, class string) []byte {
 	if raw == nil || tag == "" || class == "" {
 		return nil
 	}
-	z := html.NewTokenizer(bytes.NewReader(raw))
-	var b bytes.Buffer
-	for {
-		tt := z.Next()
-		t := z.Token()
-		if err := z.Err(); err == io.EOF {
-			break
-		}
-		switch tt {
-		case html.StartTagToken:
-			if tag == t.Data {
-				for _, a := range t.Attr {
-					if a.Key == "class" && a.Val == class {
-						b.Write(z.Buffered())
-					}
-				}
-			}
-		}
-	}
-	return b.Bytes()
+	return joinElements(raw, And(Tag(tag), Attr("class", class)))
 }
 
 func ElementsByTagAndId(doc *html.Node, tag, id string) []*html.Node {
@@ -482,29 +350,13 @@ func ElementsByTagAndId(doc *html.Node, tag, id string) []*html.Node {
 	return nodes
 }
 
+// ElementsByTagAndId2 returns the raw bytes of the first element of raw
+// named tag with the id attribute id, up to its end tag.
 func ElementsByTagAndId2(raw []byte, tag, id string) []byte {
 	if raw == nil || tag == "" || id == "" {
 		return nil
 	}
-	z := html.NewTokenizer(bytes.NewReader(raw))
-	for {
-		tt := z.Next()
-		t := z.Token()
-		if err := z.Err(); err != nil && err == io.EOF {
-			break
-		}
-		switch tt {
-		case html.StartTagToken:
-			if tag == t.Data {
-				for _, a := range t.Attr {
-					if a.Key == "id" && a.Val == id {
-						return z.Buffered()
-					}
-				}
-			}
-		}
-	}
-	return nil
+	return extractFirstElement(raw, And(Tag(tag), ID(id)))
 }
 
 func ElementsByTagAndType(doc *html.Node, tag, attrType string) []*html.Node {
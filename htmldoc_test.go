@@ -2,6 +2,7 @@ package exhtml
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -14,9 +15,23 @@ import (
 
 var u, err = url.Parse("https://cn.nikkei.com/industry/itelectric-appliance/46280-2021-10-09-01-47-33.html?tmpl=component&print=1&page=")
 
+var record = flag.Bool("record", false, "record testdata/fixtures from the live sites")
+
+// fixtures returns a Fetcher serving the live sites from testdata/fixtures.
+func fixtures() *Fetcher {
+	mode := ModeReplay
+	if *record {
+		mode = ModeRecord
+	}
+	return NewFetcher(
+		WithTransport(NewRecorder("testdata/fixtures", mode)),
+		WithRetryPolicy(&BackoffPolicy{MaxAttempts: 1}),
+	)
+}
+
 func TestExtractRssGuids(t *testing.T) {
 	src := "https://zh.vietnamplus.vn/rss/news.rss"
-	ls, err := ExtractRssGuids(src)
+	ls, err := fixtures().ExtractRssGuids(src)
 	if err != nil {
 		t.Error(err)
 	}
@@ -27,7 +42,7 @@ func TestExtractRssGuids(t *testing.T) {
 
 func TestExtractRss(t *testing.T) {
 	src := "https://china.kyodonews.net/rss/news.xml"
-	ls, err := ExtractRss(src)
+	ls, err := fixtures().ExtractRss(src)
 	if err != nil {
 		t.Error(err)
 	}
@@ -37,7 +52,7 @@ func TestExtractRss(t *testing.T) {
 }
 
 func TestGetRawAndDoc(t *testing.T) {
-	raw, doc, err := GetRawAndDoc(u, 2*time.Second)
+	raw, doc, err := fixtures().GetRawAndDoc(u, 2*time.Second)
 	if err != nil {
 		t.Error(err)
 		return
@@ -52,7 +67,7 @@ func TestTagWithAttr(t *testing.T) {
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	_, doc, err := GetRawAndDoc(u, 1*time.Minute)
+	_, doc, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -69,7 +84,7 @@ func TestDivWithAttr(t *testing.T) {
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	_, doc, err := GetRawAndDoc(u, 1*time.Minute)
+	_, doc, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -83,7 +98,7 @@ func TestDivWithAttr2(t *testing.T) {
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	raw, _, err := GetRawAndDoc(u, 1*time.Minute)
+	raw, _, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -92,11 +107,11 @@ func TestDivWithAttr2(t *testing.T) {
 }
 
 func TestElementsByTag(t *testing.T) {
-	u, err = url.Parse("https://www.bbc.com/zhongwen/simp/world-55655858")
+	u, err := url.Parse("https://www.bbc.com/zhongwen/simp/world-55655858")
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	_, doc, err := GetRawAndDoc(u, 1*time.Minute)
+	_, doc, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -114,7 +129,7 @@ func TestElementsByTag2(t *testing.T) {
 	// if err != nil {
 	//         t.Errorf("url Parse err: %v", err)
 	// }
-	// raw, _, err := GetRawAndDoc(u, 1*time.Minute)
+	// raw, _, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	// if err != nil {
 	//         t.Errorf("GetRawAndDoc err: %v", err)
 	// }
@@ -122,11 +137,11 @@ func TestElementsByTag2(t *testing.T) {
 	// b := ElementsByTag2(a, "p")
 	// fmt.Printf("a: %s\n\nb: %s", a, b)
 
-	u, err = url.Parse("https://www.voachinese.com/a/Washington-Post-Chinese-missile-silos-icbm-20210630/5948766.html")
+	u, err := url.Parse("https://www.voachinese.com/a/Washington-Post-Chinese-missile-silos-icbm-20210630/5948766.html")
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	raw, _, err := GetRawAndDoc(u, 1*time.Minute)
+	raw, _, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -136,7 +151,7 @@ func TestElementsByTag2(t *testing.T) {
 }
 
 func TestElementsByTagAndClass(t *testing.T) {
-	s, err := ioutil.ReadFile("./test.html")
+	s, err := ioutil.ReadFile("testdata/test.html")
 	if err != nil {
 		t.Errorf("read file err: %v", err)
 	}
@@ -154,10 +169,11 @@ func TestElementsByTagAndClass(t *testing.T) {
 }
 
 func TestElementsByTagAndClass2(t *testing.T) {
+	u, err := url.Parse("https://www.voachinese.com/a/Washington-Post-Chinese-missile-silos-icbm-20210630/5948766.html")
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	raw, _, err := GetRawAndDoc(u, 1*time.Minute)
+	raw, _, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -166,10 +182,11 @@ func TestElementsByTagAndClass2(t *testing.T) {
 }
 
 func TestElementsByTagAndId(t *testing.T) {
+	u, err := url.Parse("https://www.voachinese.com/a/Washington-Post-Chinese-missile-silos-icbm-20210630/5948766.html")
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	_, doc, err := GetRawAndDoc(u, 1*time.Minute)
+	_, doc, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -193,10 +210,11 @@ func TestElementsByTagAndId(t *testing.T) {
 }
 
 func TestMetaByProperty(t *testing.T) {
+	u, err := url.Parse("https://www.voachinese.com/a/Washington-Post-Chinese-missile-silos-icbm-20210630/5948766.html")
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	_, doc, err := GetRawAndDoc(u, 1*time.Minute)
+	_, doc, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -217,11 +235,11 @@ func TestMetaByProperty(t *testing.T) {
 }
 
 func TestMetaByItemprop(t *testing.T) {
-	u, err = url.Parse("https://www.cna.com.tw/news/aopl/202009290075.aspx")
+	u, err := url.Parse("https://www.cna.com.tw/news/aopl/202009290075.aspx")
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	_, doc, err := GetRawAndDoc(u, 1*time.Minute)
+	_, doc, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -242,10 +260,11 @@ func TestMetaByItemprop(t *testing.T) {
 }
 
 func TestMetaByName(t *testing.T) {
+	u, err := url.Parse("https://www.cna.com.tw/news/aopl/202009290075.aspx")
 	if err != nil {
 		t.Errorf("url Parse err: %v", err)
 	}
-	_, doc, err := GetRawAndDoc(u, 1*time.Minute)
+	_, doc, err := fixtures().GetRawAndDoc(u, 1*time.Minute)
 	if err != nil {
 		t.Errorf("GetRawAndDoc err: %v", err)
 	}
@@ -266,7 +285,7 @@ func TestMetaByName(t *testing.T) {
 }
 
 func TestElementsRmByTag(t *testing.T) {
-	s, err := ioutil.ReadFile("./test.html")
+	s, err := ioutil.ReadFile("testdata/test.html")
 	if err != nil {
 		t.Errorf("read file err: %v", err)
 	}
@@ -0,0 +1,403 @@
+package exhtml
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+)
+
+// LinkScope restricts the hosts of the links ExtractLinksWithOptions keeps,
+// relative to the host of the page.
+type LinkScope int
+
+const (
+	// ScopeHost keeps links to the host of the page only.
+	ScopeHost LinkScope = iota
+	// ScopeSubdomains keeps links to the host of the page and its
+	// subdomains.
+	ScopeSubdomains
+	// ScopeDomain keeps links to hosts of the registrable domain of the
+	// page, such as bbc.co.uk for www.bbc.co.uk.
+	ScopeDomain
+	// ScopeAny keeps links to any host.
+	ScopeAny
+)
+
+// TrackingParams are the query parameters NormalizeURL removes. A trailing
+// "*" matches any suffix.
+var TrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"mc_cid",
+	"mc_eid",
+	"yclid",
+	"_ga",
+	"spm",
+}
+
+// LinkOptions configures ExtractLinksWithOptions. The zero value keeps the
+// links to the host of the page as they are.
+type LinkOptions struct {
+	Scope LinkScope
+	// Include, if not empty, keeps only the links matching one of them.
+	Include []*regexp.Regexp
+	// Exclude drops the links matching any of them.
+	Exclude []*regexp.Regexp
+	// Normalize applies NormalizeURL before scoping and filtering.
+	Normalize bool
+}
+
+// keep returns u as it is kept by o, scoped to the host of origin, or
+// false if it is dropped.
+func (o *LinkOptions) keep(u, origin *url.URL) (string, bool) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+	if o.Normalize {
+		u = NormalizeURL(u)
+	}
+	if !inScope(u.Hostname(), origin.Hostname(), o.Scope) {
+		return "", false
+	}
+	s := u.String()
+	if len(o.Include) > 0 {
+		included := false
+		for _, re := range o.Include {
+			if re.MatchString(s) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return "", false
+		}
+	}
+	for _, re := range o.Exclude {
+		if re.MatchString(s) {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// inScope reports whether a link to host is in scope on a page at
+// pageHost.
+func inScope(host, pageHost string, scope LinkScope) bool {
+	host, pageHost = strings.ToLower(host), strings.ToLower(pageHost)
+	switch scope {
+	case ScopeAny:
+		return true
+	case ScopeSubdomains:
+		return host == pageHost || strings.HasSuffix(host, "."+pageHost)
+	case ScopeDomain:
+		d, err := publicsuffix.EffectiveTLDPlusOne(host)
+		if err != nil {
+			return host == pageHost
+		}
+		pd, err := publicsuffix.EffectiveTLDPlusOne(pageHost)
+		return err == nil && d == pd
+	}
+	return host == pageHost
+}
+
+// NormalizeURL returns a copy of u with the scheme and host lowercased,
+// the default port, the fragment and TrackingParams removed, an empty path
+// set to "/" and the query sorted by key.
+func NormalizeURL(u *url.URL) *url.URL {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if port := n.Port(); (n.Scheme == "http" && port == "80") || (n.Scheme == "https" && port == "443") {
+		n.Host = strings.TrimSuffix(n.Host, ":"+port)
+	}
+	n.Fragment, n.RawFragment = "", ""
+	if n.Path == "" && n.Opaque == "" {
+		n.Path, n.RawPath = "/", ""
+	}
+	if n.RawQuery != "" {
+		q := n.Query()
+		for k := range q {
+			if isTrackingParam(k) {
+				delete(q, k)
+			}
+		}
+		n.RawQuery = q.Encode()
+	}
+	n.ForceQuery = false
+	return &n
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range TrackingParams {
+		if prefix := strings.TrimSuffix(p, "*"); prefix != p {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// linksWithOptions returns the unique links of the anchors of doc, a page
+// at page, kept by opts scoped to origin. Relative links are resolved
+// against <base href> or page.
+func linksWithOptions(doc *html.Node, page, origin *url.URL, opts LinkOptions) []string {
+	base := baseOf(doc, page)
+	var links []string
+	seen := map[string]bool{}
+	for _, n := range ElementsByTag(doc, "a") {
+		href := strings.TrimSpace(attr(n, "href"))
+		if href == "" {
+			continue
+		}
+		u, err := base.Parse(href)
+		if err != nil {
+			continue // ignore bad URLs
+		}
+		if s, ok := opts.keep(u, origin); ok && !seen[s] {
+			seen[s] = true
+			links = append(links, s)
+		}
+	}
+	return links
+}
+
+// Kinds of Link, named after the element the link was found on.
+const (
+	KindAnchor = "a"
+	KindLink   = "link"
+	KindArea   = "area"
+	KindIframe = "iframe"
+	KindImage  = "img"
+	KindSource = "source"
+)
+
+// Link is a link of a document.
+type Link struct {
+	// URL is the link resolved against <base href> or the page URL.
+	URL string
+	// Kind is the element the link was found on, KindAnchor for anchors.
+	Kind string
+	// Text is the anchor text, or the alt text of areas and images, with
+	// white space collapsed.
+	Text  string
+	Title string
+	// Rel are the lowercased rel values, such as "nofollow" or "next".
+	Rel []string
+	// Index is the position of the link among the links returned.
+	Index int
+	// Path is the tag and class path of the elements enclosing the
+	// link, such as "html > body > div.list.news > ul > li".
+	Path string
+}
+
+// HasRel reports whether l has the rel value rel, ignoring case.
+func (l *Link) HasRel(rel string) bool {
+	for _, r := range l.Rel {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractLinkObjectsFromNode returns the http and https anchors of doc in
+// document order. Relative links are resolved against <base href> or base.
+func ExtractLinkObjectsFromNode(doc *html.Node, base *url.URL) []Link {
+	return linksFromNode(doc, base, map[string]bool{KindAnchor: true})
+}
+
+// LinksFromNode returns the http and https links of doc in document order:
+// <a href>, <link href>, <area href>, <iframe src>, <img src srcset> and
+// <source src srcset>. Relative links are resolved against <base href> or
+// base.
+func LinksFromNode(doc *html.Node, base *url.URL) []Link {
+	return linksFromNode(doc, base, nil)
+}
+
+// LinksFromRaw parses raw, UTF-8 HTML like the one GetRawAndDoc returns,
+// and returns its links like LinksFromNode.
+func LinksFromRaw(raw []byte, base *url.URL) ([]Link, error) {
+	doc, err := html.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, errors.WithMessage(err, "exhtml: LinksFromRaw")
+	}
+	return LinksFromNode(doc, base), nil
+}
+
+// linksFromNode returns the links of doc of the given kinds, of every kind
+// if kinds is nil.
+func linksFromNode(doc *html.Node, base *url.URL, kinds map[string]bool) []Link {
+	if doc == nil {
+		return nil
+	}
+	base = baseOf(doc, base)
+	var links []Link
+	add := func(n *html.Node, href string) {
+		if strings.TrimSpace(href) == "" {
+			return
+		}
+		u, err := resolve(base, href)
+		if err != nil || !(strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")) {
+			return
+		}
+		l := Link{
+			URL:   u,
+			Kind:  n.Data,
+			Title: strings.TrimSpace(attr(n, "title")),
+			Index: len(links),
+			Path:  nodePath(n.Parent),
+		}
+		switch n.Data {
+		case KindAnchor:
+			l.Text = strings.Join(strings.Fields(nodeText(n)), " ")
+		case KindArea, KindImage:
+			l.Text = strings.Join(strings.Fields(attr(n, "alt")), " ")
+		}
+		if rel := attr(n, "rel"); strings.TrimSpace(rel) != "" {
+			l.Rel = strings.Fields(strings.ToLower(rel))
+		}
+		links = append(links, l)
+	}
+	ForEachNode(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode || (kinds != nil && !kinds[n.Data]) {
+			return
+		}
+		switch n.Data {
+		case KindAnchor, KindLink, KindArea:
+			add(n, attr(n, "href"))
+		case KindIframe:
+			add(n, attr(n, "src"))
+		case KindImage, KindSource:
+			add(n, attr(n, "src"))
+			for _, c := range srcsetURLs(attr(n, "srcset")) {
+				add(n, c)
+			}
+		}
+	}, nil)
+	return links
+}
+
+// srcsetURLs returns the URLs of the image candidates of a srcset
+// attribute, such as "a.jpg 1x, b.jpg 2x".
+func srcsetURLs(srcset string) []string {
+	var urls []string
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return urls
+		}
+		i := strings.IndexAny(s, " \t\n\r\f")
+		if i < 0 {
+			i = len(s)
+		}
+		u := s[:i]
+		s = s[i:]
+		if strings.HasSuffix(u, ",") {
+			// a candidate without descriptors
+			u = strings.TrimRight(u, ",")
+		} else {
+			// skip the descriptors up to the next comma outside parens
+			depth := 0
+			j := 0
+			for ; j < len(s); j++ {
+				if s[j] == '(' {
+					depth++
+				} else if s[j] == ')' && depth > 0 {
+					depth--
+				} else if s[j] == ',' && depth == 0 {
+					break
+				}
+			}
+			s = s[j:]
+		}
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+}
+
+// nodePath returns the tag and class path from the root element to n.
+func nodePath(n *html.Node) string {
+	var path []string
+	for ; n != nil; n = n.Parent {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		p := n.Data
+		for _, c := range strings.Fields(attr(n, "class")) {
+			p += "." + c
+		}
+		path = append([]string{p}, path...)
+	}
+	return strings.Join(path, " > ")
+}
+
+// ExtractLinkObjects makes an HTTP GET request to weburl, parses the
+// response as HTML, and returns its anchors as Links.
+func (f *Fetcher) ExtractLinkObjects(weburl string) ([]Link, error) {
+	return f.ExtractLinkObjectsContext(context.Background(), weburl)
+}
+
+// ExtractLinkObjectsContext is like ExtractLinkObjects with a cancelable ctx.
+func (f *Fetcher) ExtractLinkObjectsContext(ctx context.Context, weburl string) ([]Link, error) {
+	_, doc, resp, err := f.getDoc(ctx, weburl)
+	if err != nil {
+		return nil, err
+	}
+	return ExtractLinkObjectsFromNode(doc, resp.Request.URL), nil
+}
+
+// ExtractLinkObjects makes an HTTP GET request to weburl, parses the
+// response as HTML, and returns its anchors as Links.
+func ExtractLinkObjects(weburl string) ([]Link, error) {
+	return defaultFetcher.ExtractLinkObjects(weburl)
+}
+
+// ExtractLinkObjectsContext is like ExtractLinkObjects with a cancelable ctx.
+func ExtractLinkObjectsContext(ctx context.Context, weburl string) ([]Link, error) {
+	return defaultFetcher.ExtractLinkObjectsContext(ctx, weburl)
+}
+
+// ExtractLinksWithOptions makes an HTTP GET request to weburl, parses the
+// response as HTML, and returns the unique links in the document kept by
+// opts, in document order.
+func (f *Fetcher) ExtractLinksWithOptions(weburl string, opts LinkOptions) ([]string, error) {
+	return f.ExtractLinksWithOptionsContext(context.Background(), weburl, opts)
+}
+
+// ExtractLinksWithOptionsContext is like ExtractLinksWithOptions with a
+// cancelable ctx.
+func (f *Fetcher) ExtractLinksWithOptionsContext(ctx context.Context, weburl string, opts LinkOptions) ([]string, error) {
+	_, doc, resp, err := f.getDoc(ctx, weburl)
+	if err != nil {
+		return nil, err
+	}
+	return linksWithOptions(doc, resp.Request.URL, resp.Request.URL, opts), nil
+}
+
+// ExtractLinksWithOptions makes an HTTP GET request to weburl, parses the
+// response as HTML, and returns the unique links in the document kept by
+// opts, in document order.
+func ExtractLinksWithOptions(weburl string, opts LinkOptions) ([]string, error) {
+	return defaultFetcher.ExtractLinksWithOptions(weburl, opts)
+}
+
+// ExtractLinksWithOptionsContext is like ExtractLinksWithOptions with a
+// cancelable ctx.
+func ExtractLinksWithOptionsContext(ctx context.Context, weburl string, opts LinkOptions) ([]string, error) {
+	return defaultFetcher.ExtractLinksWithOptionsContext(ctx, weburl, opts)
+}
@@ -0,0 +1,169 @@
+package exhtml
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestExtractLinksWithOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><base href="/news/"></head><body>
+<a href="article/123">relative</a>
+<a href="/world/456#comments">absolute path</a>
+<a href="http://evil.com/?r=127.0.0.1">evil</a>
+<a href="https://www.bbc.com/news">other host</a>
+<a href="article/123?utm_source=rss&b=2&a=1">tracked</a>
+<a href="article/123">duplicate</a>
+<a href="mailto:news@bbc.com">mail</a>
+<a href="/video/789">video</a>
+</body></html>`)
+	}))
+	defer ts.Close()
+
+	got, err := ExtractLinksWithOptions(ts.URL+"/index", LinkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		ts.URL + "/news/article/123",
+		ts.URL + "/world/456#comments",
+		ts.URL + "/news/article/123?utm_source=rss&b=2&a=1",
+		ts.URL + "/video/789",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got: %v", want, got)
+	}
+
+	got, err = ExtractLinksWithOptions(ts.URL+"/index", LinkOptions{
+		Scope:     ScopeAny,
+		Normalize: true,
+		Include:   []*regexp.Regexp{regexp.MustCompile(`/(news|world)/`), regexp.MustCompile(`bbc\.com`)},
+		Exclude:   []*regexp.Regexp{regexp.MustCompile(`/world/`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		ts.URL + "/news/article/123",
+		"https://www.bbc.com/news",
+		ts.URL + "/news/article/123?a=1&b=2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got: %v", want, got)
+	}
+}
+
+func TestInScope(t *testing.T) {
+	tcs := []struct {
+		host  string
+		scope LinkScope
+		want  bool
+	}{
+		{"www.bbc.co.uk", ScopeHost, true},
+		{"WWW.BBC.CO.UK", ScopeHost, true},
+		{"news.bbc.co.uk", ScopeHost, false},
+		{"m.www.bbc.co.uk", ScopeSubdomains, true},
+		{"news.bbc.co.uk", ScopeSubdomains, false},
+		{"news.bbc.co.uk", ScopeDomain, true},
+		{"bbc.co.uk", ScopeDomain, true},
+		{"evil.co.uk", ScopeDomain, false},
+		{"evil.com", ScopeAny, true},
+	}
+	for _, tc := range tcs {
+		if got := inScope(tc.host, "www.bbc.co.uk", tc.scope); got != tc.want {
+			t.Errorf("%s in scope %d: want %v, got %v", tc.host, tc.scope, tc.want, got)
+		}
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tcs := map[string]string{
+		"HTTP://WWW.BBC.COM:80":                              "http://www.bbc.com/",
+		"https://www.bbc.com:443/news?b=2&a=1#top":           "https://www.bbc.com/news?a=1&b=2",
+		"https://www.bbc.com/news?utm_medium=x&UTM_Source=y": "https://www.bbc.com/news",
+		"https://www.bbc.com:8443/news?fbclid=1&id=7":        "https://www.bbc.com:8443/news?id=7",
+		"https://www.bbc.com/News/":                          "https://www.bbc.com/News/",
+	}
+	for in, want := range tcs {
+		u, err := url.Parse(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := NormalizeURL(u).String(); got != want {
+			t.Errorf("%s: want %s, got %s", in, want, got)
+		}
+	}
+}
+
+func TestExtractLinkObjects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><div class="list news"><ul>
+<li><a href="/news/1" title="First">  First
+  story </a></li>
+<li><a href="javascript:void(0)">share</a></li>
+<li><a href="?page=2" rel="Next nofollow">Next</a></li>
+</ul></div></body></html>`)
+	}))
+	defer ts.Close()
+
+	got, err := ExtractLinkObjects(ts.URL + "/news/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Link{
+		{URL: ts.URL + "/news/1", Kind: KindAnchor, Text: "First story", Title: "First", Index: 0, Path: "html > body > div.list.news > ul > li"},
+		{URL: ts.URL + "/news/?page=2", Kind: KindAnchor, Text: "Next", Rel: []string{"next", "nofollow"}, Index: 1, Path: "html > body > div.list.news > ul > li"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got: %+v", want, got)
+	}
+	if !got[1].HasRel("NoFollow") || got[0].HasRel("next") {
+		t.Errorf("unexpected rel of %+v", got)
+	}
+}
+
+func TestLinksFromRaw(t *testing.T) {
+	base, _ := url.Parse("https://www.bbc.com/news/")
+	links, err := LinksFromRaw([]byte(`<html><head>
+<link rel="canonical" href="https://www.bbc.com/news/world">
+<link rel="stylesheet" href="/style.css">
+</head><body>
+<a href="1">one</a>
+<map><area href="/map/2" alt="two"></map>
+<iframe src="https://www.youtube.com/embed/3"></iframe>
+<img src="4.jpg" srcset="4-1x.jpg 1x, 4-2x.jpg 2x,4-3x.jpg" alt="four">
+<img src="data:image/gif;base64,R0lGOD">
+<picture><source srcset="5.webp 480w, 5-big.webp (max-width: 600px) 800w"></picture>
+</body></html>`), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range links {
+		got = append(got, l.Kind+" "+l.URL+" "+l.Text)
+	}
+	want := []string{
+		"link https://www.bbc.com/news/world ",
+		"link https://www.bbc.com/style.css ",
+		"a https://www.bbc.com/news/1 one",
+		"area https://www.bbc.com/map/2 two",
+		"iframe https://www.youtube.com/embed/3 ",
+		"img https://www.bbc.com/news/4.jpg four",
+		"img https://www.bbc.com/news/4-1x.jpg four",
+		"img https://www.bbc.com/news/4-2x.jpg four",
+		"img https://www.bbc.com/news/4-3x.jpg four",
+		"source https://www.bbc.com/news/5.webp ",
+		"source https://www.bbc.com/news/5-big.webp ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got: %q", want, got)
+	}
+	if !links[0].HasRel("canonical") || links[len(links)-1].Index != len(links)-1 {
+		t.Errorf("unexpected links: %+v", links)
+	}
+}
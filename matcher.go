@@ -0,0 +1,202 @@
+package exhtml
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Matcher reports whether a node matches a rule. Matchers compose with
+// And, Or and Not, and methods such as ClassMatcher.Match and
+// Selector.Match can be used as Matchers.
+type Matcher func(n *html.Node) bool
+
+// Tag matches the elements with any of the names.
+func Tag(names ...string) Matcher {
+	return func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+		for _, name := range names {
+			if n.Data == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Attr matches the elements having the attribute key, with any of values
+// if there are some.
+func Attr(key string, values ...string) Matcher {
+	return func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+		for _, a := range n.Attr {
+			if a.Key != key {
+				continue
+			}
+			if len(values) == 0 {
+				return true
+			}
+			for _, v := range values {
+				if a.Val == v {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// AttrRegex matches the elements whose attribute key matches re.
+func AttrRegex(key string, re *regexp.Regexp) Matcher {
+	return func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+		for _, a := range n.Attr {
+			if a.Key == key && re.MatchString(a.Val) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Class matches the elements having all of classes among the tokens of
+// their class attribute, see ClassMatcher for other rules.
+func Class(classes ...string) Matcher {
+	return AllClasses(classes...).Match
+}
+
+// ID matches the element with the id attribute id.
+func ID(id string) Matcher {
+	return Attr("id", id)
+}
+
+// Text matches the elements whose text, the text of their descendants,
+// contains s.
+func Text(s string) Matcher {
+	return func(n *html.Node) bool {
+		return n.Type == html.ElementNode && strings.Contains(nodeText(n), s)
+	}
+}
+
+// And matches the nodes matched by all of ms.
+func And(ms ...Matcher) Matcher {
+	return func(n *html.Node) bool {
+		for _, m := range ms {
+			if !m(n) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches the nodes matched by any of ms.
+func Or(ms ...Matcher) Matcher {
+	return func(n *html.Node) bool {
+		for _, m := range ms {
+			if m(n) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches the elements not matched by m.
+func Not(m Matcher) Matcher {
+	return func(n *html.Node) bool {
+		return n.Type == html.ElementNode && !m(n)
+	}
+}
+
+// HasChild matches the nodes with a child matched by m.
+func HasChild(m Matcher) Matcher {
+	return func(n *html.Node) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if m(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasAncestor matches the nodes with an ancestor matched by m.
+func HasAncestor(m Matcher) Matcher {
+	return func(n *html.Node) bool {
+		for p := n.Parent; p != nil; p = p.Parent {
+			if m(p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FindAll returns doc and its descendants matched by m in document order.
+func FindAll(doc *html.Node, m Matcher) []*html.Node {
+	var nodes []*html.Node
+	if doc == nil {
+		return nil
+	}
+	ForEachNode(doc, func(n *html.Node) {
+		if m(n) {
+			nodes = append(nodes, n)
+		}
+	}, nil)
+	return nodes
+}
+
+// FindFirst returns the first of doc and its descendants matched by m, or
+// nil.
+func FindFirst(doc *html.Node, m Matcher) *html.Node {
+	if doc == nil {
+		return nil
+	}
+	if m(doc) {
+		return doc
+	}
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if n := FindFirst(c, m); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// Closest returns the first of n and its ancestors matched by m, or nil.
+func Closest(n *html.Node, m Matcher) *html.Node {
+	for ; n != nil; n = n.Parent {
+		if m(n) {
+			return n
+		}
+	}
+	return nil
+}
+
+// RemoveAll removes the descendants of doc matched by m and returns how
+// many were removed. The descendants of a removed node are not counted.
+func RemoveAll(doc *html.Node, m Matcher) int {
+	if doc == nil {
+		return 0
+	}
+	removed := 0
+	for c := doc.FirstChild; c != nil; {
+		next := c.NextSibling
+		if m(c) {
+			doc.RemoveChild(c)
+			removed++
+		} else {
+			removed += RemoveAll(c, m)
+		}
+		c = next
+	}
+	return removed
+}
@@ -0,0 +1,91 @@
+package exhtml
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+var testMatcherDoc = `<html><head>
+<meta name="description" content="news">
+<meta property="og:title" content="Title">
+<script type="application/ld+json">{}</script>
+</head><body>
+<article id="a1" class="story main">
+  <h1 id="h1">Headline</h1>
+  <div id="d1" class="body"><p id="p1">one <span id="s1">ad</span></p><p id="p2" class="ad">two</p></div>
+</article>
+<div id="d2"><img id="i1" src="https://cdn.example.com/1.jpg"><img id="i2" src="/2.png"></div>
+</body></html>`
+
+func matcherIDs(nodes []*html.Node) string {
+	var ids []string
+	for _, n := range nodes {
+		id := attr(n, "id")
+		if id == "" {
+			id = n.Data
+		}
+		ids = append(ids, id)
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestFindAll(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testMatcherDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		name string
+		m    Matcher
+		want string
+	}{
+		{"tag", Tag("h1", "img"), "h1 i1 i2"},
+		{"attr", And(Tag("meta"), Attr("name", "description", "keywords")), "meta"},
+		{"attr presence", And(Tag("meta"), Attr("property")), "meta"},
+		{"type", And(Tag("script"), Attr("type", "application/ld+json")), "script"},
+		{"regex", AttrRegex("src", regexp.MustCompile(`^https?://`)), "i1"},
+		{"class", Class("story", "main"), "a1"},
+		{"id", ID("d2"), "d2"},
+		{"text", And(Tag("p"), Text("ad")), "p1"},
+		{"not", And(Tag("p"), Not(Class("ad"))), "p1"},
+		{"or", Or(ID("h1"), ID("s1")), "h1 s1"},
+		{"has child", And(Tag("div"), HasChild(Class("ad"))), "d1"},
+		{"has ancestor", And(Tag("p"), HasAncestor(Tag("article"))), "p1 p2"},
+		{"selector", MustCompileSelector("article > h1").Match, "h1"},
+	}
+	for _, tc := range tcs {
+		if got := matcherIDs(FindAll(doc, tc.m)); got != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.name, tc.want, got)
+		}
+	}
+
+	if n := FindFirst(doc, Tag("p")); n == nil || attr(n, "id") != "p1" {
+		t.Errorf("want p1 first, got %v", n)
+	}
+	if n := FindFirst(doc, Tag("table")); n != nil {
+		t.Errorf("want nil, got %v", n)
+	}
+	s1 := FindFirst(doc, ID("s1"))
+	if n := Closest(s1, Tag("div", "article")); n == nil || attr(n, "id") != "d1" {
+		t.Errorf("want closest d1, got %v", n)
+	}
+	if n := Closest(s1, Tag("span")); n != s1 {
+		t.Errorf("want s1 itself, got %v", n)
+	}
+}
+
+func TestRemoveAll(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testMatcherDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := RemoveAll(doc, Or(Class("ad"), ID("s1"), ID("d2"))); n != 3 {
+		t.Errorf("want 3 removed, got %d", n)
+	}
+	if got := matcherIDs(FindAll(doc, Attr("id"))); got != "a1 h1 d1 p1" {
+		t.Errorf("want a1 h1 d1 p1 left, got %q", got)
+	}
+}
@@ -0,0 +1,118 @@
+package exhtml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html/charset"
+)
+
+// Subscription is a feed of an OPML subscription list.
+type Subscription struct {
+	Title string
+	// FeedURL is the xmlUrl of the outline, SiteURL its htmlUrl.
+	FeedURL string
+	SiteURL string
+	// Type is the outline type, usually "rss".
+	Type string
+	// Category is the path of the titles of the enclosing outlines.
+	Category []string
+}
+
+type opmlDoc struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title,omitempty"`
+		DateCreated string `xml:"dateCreated,omitempty"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []*opmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type opmlOutline struct {
+	Text     string         `xml:"text,attr"`
+	Title    string         `xml:"title,attr,omitempty"`
+	Type     string         `xml:"type,attr,omitempty"`
+	XMLURL   string         `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string         `xml:"htmlUrl,attr,omitempty"`
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+func (o *opmlOutline) title() string {
+	if t := strings.TrimSpace(o.Title); t != "" {
+		return t
+	}
+	return strings.TrimSpace(o.Text)
+}
+
+// ParseOPML returns the subscriptions of an OPML document, flattening
+// nested outlines into Subscription.Category.
+func ParseOPML(raw []byte) ([]Subscription, error) {
+	d := xml.NewDecoder(bytes.NewReader(raw))
+	d.CharsetReader = charset.NewReaderLabel
+	var doc opmlDoc
+	if err := d.Decode(&doc); err != nil {
+		return nil, errors.WithMessage(err, "exhtml: ParseOPML")
+	}
+	var subs []Subscription
+	var walk func(outlines []*opmlOutline, category []string)
+	walk = func(outlines []*opmlOutline, category []string) {
+		for _, o := range outlines {
+			if u := strings.TrimSpace(o.XMLURL); u != "" {
+				subs = append(subs, Subscription{
+					Title:    o.title(),
+					FeedURL:  u,
+					SiteURL:  strings.TrimSpace(o.HTMLURL),
+					Type:     o.Type,
+					Category: category,
+				})
+			}
+			if len(o.Outlines) > 0 {
+				walk(o.Outlines, append(category[:len(category):len(category)], o.title()))
+			}
+		}
+	}
+	walk(doc.Body.Outlines, nil)
+	return subs, nil
+}
+
+// WriteOPML writes subs to w as an OPML 2.0 document titled title,
+// nesting them in outlines by Category.
+func WriteOPML(w io.Writer, title string, subs []Subscription) error {
+	var doc opmlDoc
+	doc.Version = "2.0"
+	doc.Head.Title = title
+	doc.Head.DateCreated = time.Now().UTC().Format(time.RFC1123Z)
+	folders := map[string]*opmlOutline{}
+	for _, s := range subs {
+		parent := &doc.Body.Outlines
+		for i, c := range s.Category {
+			key := strings.Join(s.Category[:i+1], "\x00")
+			f, ok := folders[key]
+			if !ok {
+				f = &opmlOutline{Text: c}
+				folders[key] = f
+				*parent = append(*parent, f)
+			}
+			parent = &f.Outlines
+		}
+		typ := s.Type
+		if typ == "" {
+			typ = "rss"
+		}
+		*parent = append(*parent, &opmlOutline{
+			Text:    s.Title,
+			Title:   s.Title,
+			Type:    typ,
+			XMLURL:  s.FeedURL,
+			HTMLURL: s.SiteURL,
+		})
+	}
+	return errors.WithMessage(writeXML(w, &doc), "exhtml: WriteOPML")
+}
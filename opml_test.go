@@ -0,0 +1,54 @@
+package exhtml
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var testOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+<head><title>Subscriptions</title></head>
+<body>
+  <outline text="越通社" type="rss" xmlUrl="https://zh.vietnamplus.vn/rss/news.rss" htmlUrl="https://zh.vietnamplus.vn/"/>
+  <outline text="Asia">
+    <outline title="Japan" text="jp">
+      <outline text="共同网" type="rss" xmlUrl="https://china.kyodonews.net/rss/news.xml"/>
+    </outline>
+    <outline text="中央社" type="rss" xmlUrl="https://www.cna.com.tw/rss.xml"/>
+  </outline>
+</body>
+</opml>`
+
+func TestParseOPML(t *testing.T) {
+	subs, err := ParseOPML([]byte(testOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Subscription{
+		{Title: "越通社", FeedURL: "https://zh.vietnamplus.vn/rss/news.rss", SiteURL: "https://zh.vietnamplus.vn/", Type: "rss"},
+		{Title: "共同网", FeedURL: "https://china.kyodonews.net/rss/news.xml", Type: "rss", Category: []string{"Asia", "Japan"}},
+		{Title: "中央社", FeedURL: "https://www.cna.com.tw/rss.xml", Type: "rss", Category: []string{"Asia"}},
+	}
+	if !reflect.DeepEqual(subs, want) {
+		t.Errorf("want %+v, got: %+v", want, subs)
+	}
+}
+
+func TestWriteOPML(t *testing.T) {
+	subs, err := ParseOPML([]byte(testOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteOPML(&buf, "Subscriptions", subs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseOPML(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, subs) {
+		t.Errorf("round trip: want %+v, got: %+v\n%s", subs, got, buf.String())
+	}
+}
@@ -0,0 +1,320 @@
+package exhtml
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxSeen is the number of GUIDs a seen store keeps per feed.
+const DefaultMaxSeen = 5000
+
+// DefaultPollInterval replaces a non-positive interval given to
+// FeedPoller.Add.
+const DefaultPollInterval = 15 * time.Minute
+
+// SeenStore remembers the items a FeedPoller has emitted.
+// Implementations must be safe for concurrent use.
+type SeenStore interface {
+	Seen(feedURL, guid string) (bool, error)
+	MarkSeen(feedURL string, guids ...string) error
+}
+
+// MemorySeenStore is a SeenStore kept in memory. It forgets the oldest
+// GUIDs of a feed beyond Max.
+type MemorySeenStore struct {
+	// Max is the number of GUIDs kept per feed, DefaultMaxSeen if 0.
+	Max int
+
+	mu    sync.Mutex
+	feeds map[string]*seenList
+}
+
+type seenList struct {
+	order []string
+	set   map[string]bool
+}
+
+// NewMemorySeenStore returns an empty MemorySeenStore.
+func NewMemorySeenStore() *MemorySeenStore {
+	return &MemorySeenStore{feeds: map[string]*seenList{}}
+}
+
+// Seen implements SeenStore.
+func (s *MemorySeenStore) Seen(feedURL, guid string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.feeds[feedURL]
+	return ok && l.set[guid], nil
+}
+
+// MarkSeen implements SeenStore.
+func (s *MemorySeenStore) MarkSeen(feedURL string, guids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.markSeen(feedURL, guids...)
+	return nil
+}
+
+func (s *MemorySeenStore) markSeen(feedURL string, guids ...string) {
+	l, ok := s.feeds[feedURL]
+	if !ok {
+		l = &seenList{set: map[string]bool{}}
+		s.feeds[feedURL] = l
+	}
+	for _, g := range guids {
+		if !l.set[g] {
+			l.set[g] = true
+			l.order = append(l.order, g)
+		}
+	}
+	limit := s.Max
+	if limit <= 0 {
+		limit = DefaultMaxSeen
+	}
+	if n := len(l.order) - limit; n > 0 {
+		for _, g := range l.order[:n] {
+			delete(l.set, g)
+		}
+		l.order = append([]string(nil), l.order[n:]...)
+	}
+}
+
+// FileSeenStore is a MemorySeenStore saved to a JSON file on every change.
+type FileSeenStore struct {
+	MemorySeenStore
+	path string
+}
+
+// NewFileSeenStore returns a FileSeenStore loaded from path if it exists.
+func NewFileSeenStore(path string) (*FileSeenStore, error) {
+	s := &FileSeenStore{
+		MemorySeenStore: MemorySeenStore{feeds: map[string]*seenList{}},
+		path:            path,
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "exhtml: NewFileSeenStore")
+	}
+	var feeds map[string][]string
+	if err := json.Unmarshal(b, &feeds); err != nil {
+		return nil, errors.WithMessage(err, "exhtml: NewFileSeenStore")
+	}
+	for feedURL, guids := range feeds {
+		s.markSeen(feedURL, guids...)
+	}
+	return s, nil
+}
+
+// MarkSeen implements SeenStore.
+func (s *FileSeenStore) MarkSeen(feedURL string, guids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.markSeen(feedURL, guids...)
+	feeds := map[string][]string{}
+	for u, l := range s.feeds {
+		feeds[u] = l.order
+	}
+	b, err := json.Marshal(feeds)
+	if err != nil {
+		return errors.WithMessage(err, "exhtml: FileSeenStore.MarkSeen")
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return errors.WithMessage(err, "exhtml: FileSeenStore.MarkSeen")
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// PolledItem is a new item found by a FeedPoller.
+type PolledItem struct {
+	FeedURL string
+	Feed    *Feed
+	Item    *FeedItem
+}
+
+// FeedPoller polls feeds and emits the items it has not seen before.
+type FeedPoller struct {
+	// OnError is called with the errors of a poll, which is retried at the
+	// next interval. It may be called concurrently.
+	OnError func(feedURL string, err error)
+
+	fetcher *Fetcher
+	store   SeenStore
+	feeds   []*polledFeed
+	items   chan *PolledItem
+}
+
+type polledFeed struct {
+	url          string
+	interval     time.Duration
+	etag         string
+	lastModified string
+	// last is the last parsed feed, its hints outlive not modified polls
+	last *Feed
+}
+
+// NewFeedPoller returns a FeedPoller fetching with f, or the default
+// fetcher if f is nil, and remembering items in store, a new
+// MemorySeenStore if store is nil.
+func NewFeedPoller(f *Fetcher, store SeenStore) *FeedPoller {
+	if f == nil {
+		f = defaultFetcher
+	}
+	if store == nil {
+		store = NewMemorySeenStore()
+	}
+	return &FeedPoller{
+		fetcher: f,
+		store:   store,
+		items:   make(chan *PolledItem),
+	}
+}
+
+// Add polls feedURL every interval, or less often if the feed asks so with
+// its ttl. An interval <= 0 means DefaultPollInterval. It must be called
+// before Run.
+func (p *FeedPoller) Add(feedURL string, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	p.feeds = append(p.feeds, &polledFeed{url: feedURL, interval: interval})
+}
+
+// Items returns the channel new items are sent on. It is closed when Run
+// returns.
+func (p *FeedPoller) Items() <-chan *PolledItem {
+	return p.items
+}
+
+// Run polls all feeds until ctx is done and returns ctx.Err().
+func (p *FeedPoller) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, pf := range p.feeds {
+		wg.Add(1)
+		go func(pf *polledFeed) {
+			defer wg.Done()
+			p.loop(ctx, pf)
+		}(pf)
+	}
+	wg.Wait()
+	close(p.items)
+	return ctx.Err()
+}
+
+func (p *FeedPoller) loop(ctx context.Context, pf *polledFeed) {
+	for {
+		feed, err := p.poll(ctx, pf)
+		if err != nil && ctx.Err() == nil && p.OnError != nil {
+			p.OnError(pf.url, err)
+		}
+		if feed != nil {
+			pf.last = feed
+		}
+		if sleepContext(ctx, time.Until(nextPoll(time.Now(), pf.interval, pf.last))) != nil {
+			return
+		}
+	}
+}
+
+// poll fetches the feed once and emits its unseen items. The feed is nil
+// if it was not modified.
+func (p *FeedPoller) poll(ctx context.Context, pf *polledFeed) (*Feed, error) {
+	h := http.Header{}
+	if pf.etag != "" {
+		h.Set("If-None-Match", pf.etag)
+	}
+	if pf.lastModified != "" {
+		h.Set("If-Modified-Since", pf.lastModified)
+	}
+	resp, err := p.fetcher.requestHeader(ctx, pf.url, h)
+	if err != nil {
+		return nil, requestError(pf.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newHTTPStatusError(pf.url, resp)
+	}
+	raw, err := p.fetcher.readBody(pf.url, resp)
+	if err != nil {
+		return nil, requestError(pf.url, err)
+	}
+	feed, err := ParseFeed(raw)
+	if err != nil {
+		return nil, &ParseError{URL: pf.url, Err: err}
+	}
+	// the new GUIDs are marked seen at once, an item may be emitted again
+	// if the process stops in between
+	var emitted []string
+	for _, it := range feed.Items {
+		guid := it.GUID
+		if guid == "" {
+			guid = it.Link
+		}
+		seen, err := p.store.Seen(pf.url, guid)
+		if err != nil {
+			return feed, err
+		}
+		if seen || containsString(emitted, guid) {
+			continue
+		}
+		select {
+		case p.items <- &PolledItem{FeedURL: pf.url, Feed: feed, Item: it}:
+			emitted = append(emitted, guid)
+		case <-ctx.Done():
+			if len(emitted) > 0 {
+				p.store.MarkSeen(pf.url, emitted...)
+			}
+			return feed, ctx.Err()
+		}
+	}
+	if len(emitted) > 0 {
+		if err := p.store.MarkSeen(pf.url, emitted...); err != nil {
+			return feed, err
+		}
+	}
+	// only once every item is emitted, a 304 must not hide unseen items
+	pf.etag, pf.lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	return feed, nil
+}
+
+// nextPoll returns when to poll a feed next, honoring its ttl, skipHours
+// and skipDays. feed is nil if it was never parsed.
+func nextPoll(now time.Time, interval time.Duration, feed *Feed) time.Time {
+	if feed != nil && feed.TTL > interval {
+		interval = feed.TTL
+	}
+	next := now.Add(interval)
+	if feed == nil || (len(feed.SkipHours) == 0 && len(feed.SkipDays) == 0) {
+		return next
+	}
+	skipHour := map[int]bool{}
+	for _, h := range feed.SkipHours {
+		skipHour[h] = true
+	}
+	skipDay := map[time.Weekday]bool{}
+	for _, d := range feed.SkipDays {
+		skipDay[d] = true
+	}
+	// a week of skipped hours at most
+	for i := 0; i < 7*24; i++ {
+		u := next.UTC()
+		if !skipHour[u.Hour()] && !skipDay[u.Weekday()] {
+			break
+		}
+		next = u.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next
+}
@@ -0,0 +1,185 @@
+package exhtml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFeedPoller(t *testing.T) {
+	var mu sync.Mutex
+	guids := []string{"1", "2"}
+	notModified := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		etag := fmt.Sprintf(`"%d"`, len(guids))
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		var items strings.Builder
+		for _, g := range guids {
+			fmt.Fprintf(&items, "<item><title>%s</title><guid>%s</guid></item>", g, g)
+		}
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>t</title>%s</channel></rss>`, items.String())
+	}))
+	defer ts.Close()
+
+	p := NewFeedPoller(nil, nil)
+	p.OnError = func(feedURL string, err error) { t.Error(err) }
+	p.Add(ts.URL, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	var got []string
+	for it := range p.Items() {
+		got = append(got, it.Item.GUID)
+		if len(got) == 2 {
+			mu.Lock()
+			guids = append(guids, "3")
+			mu.Unlock()
+		}
+		if len(got) == 3 {
+			// let a few polls find nothing new
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}
+	}
+	<-done
+	if strings.Join(got, ",") != "1,2,3" {
+		t.Errorf("want 1,2,3, got: %v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if notModified == 0 {
+		t.Errorf("want conditional polls answered with 304")
+	}
+}
+
+func TestNextPoll(t *testing.T) {
+	now := time.Date(2021, 10, 9, 1, 30, 0, 0, time.UTC) // Saturday
+	tests := []struct {
+		feed *Feed
+		want time.Time
+	}{
+		{nil, now.Add(10 * time.Minute)},
+		{&Feed{TTL: time.Hour}, now.Add(time.Hour)},
+		{&Feed{SkipHours: []int{1, 2}}, time.Date(2021, 10, 9, 3, 0, 0, 0, time.UTC)},
+		{&Feed{SkipDays: []time.Weekday{time.Saturday}}, time.Date(2021, 10, 10, 0, 0, 0, 0, time.UTC)},
+	}
+	for i, tc := range tests {
+		if got := nextPoll(now, 10*time.Minute, tc.feed); !got.Equal(tc.want) {
+			t.Errorf("%d: want %v, got: %v", i, tc.want, got)
+		}
+	}
+}
+
+func TestFileSeenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.json")
+	s, err := NewFileSeenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Max = 2
+	if err := s.MarkSeen("feed", "a", "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewFileSeenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for g, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if got, _ := s.Seen("feed", g); got != want {
+			t.Errorf("%s: want %v, got: %v", g, want, got)
+		}
+	}
+}
+
+// flakySeenStore fails its first MarkSeen and counts the calls.
+type flakySeenStore struct {
+	*MemorySeenStore
+	mu     sync.Mutex
+	failed bool
+	calls  int
+}
+
+func (s *flakySeenStore) MarkSeen(feedURL string, guids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if !s.failed {
+		s.failed = true
+		return errors.New("store down")
+	}
+	return s.MemorySeenStore.MarkSeen(feedURL, guids...)
+}
+
+func TestFeedPollerStoreError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>t</title>`+
+			`<item><guid>1</guid></item><item><guid>2</guid></item></channel></rss>`)
+	}))
+	defer ts.Close()
+
+	store := &flakySeenStore{MemorySeenStore: NewMemorySeenStore()}
+	p := NewFeedPoller(nil, store)
+	var mu sync.Mutex
+	errs := 0
+	p.OnError = func(feedURL string, err error) {
+		mu.Lock()
+		errs++
+		mu.Unlock()
+	}
+	p.Add(ts.URL, 10*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	var got []string
+	for it := range p.Items() {
+		got = append(got, it.Item.GUID)
+		if len(got) == 4 {
+			// let a few polls find nothing new
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}
+	}
+	<-done
+	// the items are emitted again as they could not be marked seen
+	if strings.Join(got, ",") != "1,2,1,2" {
+		t.Errorf("want 1,2,1,2, got: %v", got)
+	}
+	if errs != 1 {
+		t.Errorf("want 1 error, got: %d", errs)
+	}
+	// one MarkSeen per poll with new items
+	if store.calls != 2 {
+		t.Errorf("want 2 MarkSeen calls, got: %d", store.calls)
+	}
+}
+
+func TestFeedPollerAddInterval(t *testing.T) {
+	p := NewFeedPoller(nil, nil)
+	p.Add("https://example.com/rss", 0)
+	if p.feeds[0].interval != DefaultPollInterval {
+		t.Errorf("want %v, got: %v", DefaultPollInterval, p.feeds[0].interval)
+	}
+}
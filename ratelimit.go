@@ -0,0 +1,152 @@
+package exhtml
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit bounds the requests a Fetcher sends to one host.
+type HostLimit struct {
+	// RPS is the sustained number of requests per second, 0 means unlimited.
+	RPS float64
+	// Burst is the number of requests allowed at once before RPS applies,
+	// values below 1 are treated as 1.
+	Burst int
+	// MaxInFlight caps concurrent requests including body reads,
+	// 0 means unlimited.
+	MaxInFlight int
+}
+
+// HostLimiter applies a HostLimit to every host separately. Limits can be
+// set per domain, a domain limit also covers its subdomains.
+type HostLimiter struct {
+	mu     sync.Mutex
+	def    HostLimit
+	limits map[string]HostLimit
+	hosts  map[string]*hostState
+}
+
+type hostState struct {
+	limit  HostLimit
+	tokens float64
+	last   time.Time
+	sem    chan struct{}
+}
+
+// NewHostLimiter returns a HostLimiter applying def to hosts without
+// a domain limit.
+func NewHostLimiter(def HostLimit) *HostLimiter {
+	return &HostLimiter{
+		def:    def,
+		limits: map[string]HostLimit{},
+		hosts:  map[string]*hostState{},
+	}
+}
+
+// WithHostLimiter makes every request of the Fetcher wait for l.
+// A limiter can be shared by several fetchers.
+func WithHostLimiter(l *HostLimiter) Option {
+	return func(f *Fetcher) {
+		f.limiter = l
+	}
+}
+
+// WithRateLimit is a shortcut of WithHostLimiter with the same limit for
+// every host.
+func WithRateLimit(rps float64, burst, maxInFlight int) Option {
+	return WithHostLimiter(NewHostLimiter(HostLimit{RPS: rps, Burst: burst, MaxInFlight: maxInFlight}))
+}
+
+// SetLimit sets the limit of domain and its subdomains. It only affects
+// hosts not requested yet.
+func (l *HostLimiter) SetLimit(domain string, lim HostLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits[strings.ToLower(domain)] = lim
+}
+
+// limitFor returns the limit of the longest configured domain host belongs to.
+func (l *HostLimiter) limitFor(host string) HostLimit {
+	lim, best := l.def, -1
+	for d, dl := range l.limits {
+		if (host == d || strings.HasSuffix(host, "."+d)) && len(d) > best {
+			lim, best = dl, len(d)
+		}
+	}
+	return lim
+}
+
+func (l *HostLimiter) state(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.hosts[host]
+	if !ok {
+		lim := l.limitFor(host)
+		if lim.Burst < 1 {
+			lim.Burst = 1
+		}
+		s = &hostState{limit: lim, tokens: float64(lim.Burst), last: time.Now()}
+		if lim.MaxInFlight > 0 {
+			s.sem = make(chan struct{}, lim.MaxInFlight)
+		}
+		l.hosts[host] = s
+	}
+	return s
+}
+
+// Wait blocks until a request to host is allowed or ctx is done.
+// The returned release must be called once the request is finished.
+func (l *HostLimiter) Wait(ctx context.Context, host string) (release func(), err error) {
+	s := l.state(strings.ToLower(host))
+	release = func() {}
+	if s.sem != nil {
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		var once sync.Once
+		release = func() { once.Do(func() { <-s.sem }) }
+	}
+	if s.limit.RPS <= 0 {
+		return release, nil
+	}
+
+	// Reserve a token, the balance goes negative for waiting requests.
+	l.mu.Lock()
+	now := time.Now()
+	s.tokens += now.Sub(s.last).Seconds() * s.limit.RPS
+	if burst := float64(s.limit.Burst); s.tokens > burst {
+		s.tokens = burst
+	}
+	s.last = now
+	s.tokens--
+	wait := time.Duration(-s.tokens / s.limit.RPS * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait > 0 {
+		if err := sleepContext(ctx, wait); err != nil {
+			l.mu.Lock()
+			s.tokens++
+			l.mu.Unlock()
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// releaseBody calls release when the body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,94 @@
+package exhtml
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterRPS(t *testing.T) {
+	l := NewHostLimiter(HostLimit{RPS: 50, Burst: 1})
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		release, err := l.Wait(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	// the first request uses the burst, the other five wait 20ms each
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("want at least 100ms for 6 requests at 50 rps, got: %v", d)
+	}
+}
+
+func TestHostLimiterSetLimit(t *testing.T) {
+	l := NewHostLimiter(HostLimit{RPS: 1})
+	l.SetLimit("bbc.com", HostLimit{RPS: 5})
+	l.SetLimit("news.bbc.com", HostLimit{RPS: 10})
+	tests := []struct {
+		host string
+		want float64
+	}{
+		{"bbc.com", 5},
+		{"www.bbc.com", 5},
+		{"news.bbc.com", 10},
+		{"a.news.bbc.com", 10},
+		{"notbbc.com", 1},
+	}
+	for _, tc := range tests {
+		if got := l.limitFor(tc.host).RPS; got != tc.want {
+			t.Errorf("%s: want %v, got: %v", tc.host, tc.want, got)
+		}
+	}
+}
+
+func TestHostLimiterCanceled(t *testing.T) {
+	l := NewHostLimiter(HostLimit{RPS: 0.001})
+	if _, err := l.Wait(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx, "example.com"); err == nil {
+		t.Errorf("want error from canceled wait, got nil")
+	}
+}
+
+func TestFetcherMaxInFlight(t *testing.T) {
+	var cur, peak int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&cur, 1)
+		for {
+			m := atomic.LoadInt32(&peak)
+			if n <= m || atomic.CompareAndSwapInt32(&peak, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&cur, -1)
+		fmt.Fprint(w, "<p>ok</p>")
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(WithRateLimit(0, 0, 2))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := f.ExtractLinks(ts.URL); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Errorf("want at most 2 requests in flight, got: %d", peak)
+	}
+}
@@ -0,0 +1,188 @@
+package exhtml
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RecordMode selects what a Recorder does with a request.
+type RecordMode int
+
+const (
+	// ModeReplay serves every request from its fixture and fails if
+	// there is none.
+	ModeReplay RecordMode = iota
+	// ModeRecord sends every request and overwrites its fixture.
+	ModeRecord
+	// ModeReplayOrRecord serves existing fixtures and records missing ones.
+	ModeReplayOrRecord
+)
+
+// Recorder is an http.RoundTripper that records requests and their
+// responses to fixture files and replays them, so code fetching live sites
+// can be tested offline. A fixture is the request line and headers
+// followed by the response as sent on the wire, without Content-Length and
+// Transfer-Encoding, so its body can be edited by hand. Request bodies and
+// the headers of recordedHeaderSkip are not recorded. A fixture may also
+// hold the response alone.
+type Recorder struct {
+	// Dir holds the fixture files.
+	Dir string
+	// Mode defaults to ModeReplay.
+	Mode RecordMode
+	// Transport sends the recorded requests, http.DefaultTransport if nil.
+	Transport http.RoundTripper
+}
+
+// recordedHeaderSkip are the headers left out of fixtures, credentials
+// and framing.
+var recordedHeaderSkip = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+	"Content-Length":      true,
+	"Transfer-Encoding":   true,
+}
+
+// NewRecorder returns a Recorder keeping its fixtures in dir.
+func NewRecorder(dir string, mode RecordMode) *Recorder {
+	return &Recorder{Dir: dir, Mode: mode}
+}
+
+// FixtureName returns the file name the fixture of req is stored under.
+func FixtureName(req *http.Request) string {
+	u := *req.URL
+	u.Fragment = ""
+	s := u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		s += "?" + u.RawQuery
+	}
+	if req.Method != "GET" && req.Method != "" {
+		s = req.Method + "_" + s
+	}
+	name := []byte(s)
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-') {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 120 {
+		sum := sha1.Sum([]byte(s))
+		name = append(name[:111], '-')
+		name = append(name, hex.EncodeToString(sum[:4])...)
+	}
+	return string(name) + ".http"
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := filepath.Join(r.Dir, FixtureName(req))
+	if r.Mode != ModeRecord {
+		resp, err := r.replay(path, req)
+		if err == nil || r.Mode == ModeReplay || !os.IsNotExist(errors.Cause(err)) {
+			return resp, err
+		}
+	}
+	return r.record(path, req)
+}
+
+func (r *Recorder) replay(path string, req *http.Request) (*http.Response, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.WithMessagef(err, "exhtml: no fixture for %s, record it first", req.URL)
+		}
+		return nil, err
+	}
+	br := bufio.NewReader(bytes.NewReader(b))
+	if !bytes.HasPrefix(b, []byte("HTTP/")) {
+		recorded, err := http.ReadRequest(br)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "exhtml: reading fixture %s", path)
+		}
+		if recorded.Method != fixtureMethod(req) || recorded.URL.String() != fixtureURL(req) {
+			return nil, errors.Errorf("exhtml: fixture %s was recorded for %s %s", path, recorded.Method, recorded.URL)
+		}
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "exhtml: reading fixture %s", path)
+	}
+	return resp, nil
+}
+
+// fixtureMethod returns the method of req, GET if empty.
+func fixtureMethod(req *http.Request) string {
+	if req.Method == "" {
+		return "GET"
+	}
+	return req.Method
+}
+
+// fixtureURL returns the URL of req as recorded, without fragment.
+func fixtureURL(req *http.Request) string {
+	u := *req.URL
+	u.Fragment = ""
+	return u.String()
+}
+
+// writeHeader writes h sorted by key, without recordedHeaderSkip.
+func writeHeader(b *bytes.Buffer, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if recordedHeaderSkip[k] {
+			continue
+		}
+		for _, v := range h[k] {
+			fmt.Fprintf(b, "%s: %s\r\n", k, strings.NewReplacer("\r", " ", "\n", " ").Replace(v))
+		}
+	}
+	b.WriteString("\r\n")
+}
+
+func (r *Recorder) record(path string, req *http.Request) (*http.Response, error) {
+	rt := r.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", fixtureMethod(req), fixtureURL(req))
+	writeHeader(&b, req.Header)
+	fmt.Fprintf(&b, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	writeHeader(&b, resp.Header)
+	b.Write(body)
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return nil, errors.WithMessagef(err, "exhtml: writing fixture %s", path)
+	}
+	return resp, nil
+}
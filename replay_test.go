@@ -0,0 +1,105 @@
+package exhtml
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<a href="/news/1">news</a>`)
+	}))
+	dir := t.TempDir()
+	links, err := NewFetcher(WithTransport(NewRecorder(dir, ModeRecord))).ExtractLinks(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+
+	// the server is gone, only the fixture can answer
+	f := NewFetcher(WithTransport(NewRecorder(dir, ModeReplay)))
+	replayed, err := f.ExtractLinks(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || len(replayed) != 1 || links[0] != replayed[0] {
+		t.Errorf("want %v, got: %v", links, replayed)
+	}
+	_, err = f.ExtractLinks(ts.URL + "/missing")
+	if err == nil || !strings.Contains(err.Error(), "no fixture") {
+		t.Errorf("want missing fixture error, got: %v", err)
+	}
+}
+
+func TestRecorderReplayOrRecord(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		fmt.Fprint(w, "<p>ok</p>")
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(WithTransport(NewRecorder(t.TempDir(), ModeReplayOrRecord)))
+	u, _ := url.Parse(ts.URL + "/a")
+	for i := 0; i < 3; i++ {
+		if _, _, err := f.GetRawAndDoc(u, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("want 1 request to the server, got: %d", hits)
+	}
+}
+
+func TestFixtureName(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://zh.vietnamplus.vn/Utilities/Print.aspx?contentid=143985#top", nil)
+	if got, want := FixtureName(req), "zh.vietnamplus.vn_Utilities_Print.aspx_contentid_143985.http"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+	req, _ = http.NewRequest("GET", "https://example.com/"+strings.Repeat("a", 200), nil)
+	if got := FixtureName(req); len(got) != 125 {
+		t.Errorf("want long names shortened to 125, got: %d", len(got))
+	}
+}
+
+func TestRecorderRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<p>ok</p>")
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	f := NewFetcher(WithTransport(NewRecorder(dir, ModeRecord)), WithHeader("Cookie", "session=secret"))
+	if _, err := f.ExtractLinks(ts.URL + "/a?b=1"); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", ts.URL+"/a?b=1", nil)
+	b, err := ioutil.ReadFile(filepath.Join(dir, FixtureName(req)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture := string(b)
+	if !strings.HasPrefix(fixture, "GET "+ts.URL+"/a?b=1 HTTP/1.1\r\n") ||
+		!strings.Contains(fixture, "User-Agent: ") || strings.Contains(fixture, "secret") ||
+		!strings.Contains(fixture, "\r\n\r\nHTTP/1.1 200 OK\r\n") {
+		t.Errorf("want the request without credentials then the response, got:\n%s", fixture)
+	}
+
+	// a fixture recorded for another URL sharing the name is refused
+	other := strings.Replace(fixture, "/a?b=1", "/a/b=1", 1)
+	if err := ioutil.WriteFile(filepath.Join(dir, FixtureName(req)), []byte(other), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f = NewFetcher(WithTransport(NewRecorder(dir, ModeReplay)))
+	if _, err := f.ExtractLinks(ts.URL + "/a?b=1"); err == nil || !strings.Contains(err.Error(), "recorded for") {
+		t.Errorf("want a mismatched fixture error, got: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package exhtml
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides which attempts of a request are retried and how long
+// to wait between them.
+type RetryPolicy interface {
+	// Retryable reports whether the outcome of an attempt is worth another
+	// try. resp is nil if err is not.
+	Retryable(resp *http.Response, err error) bool
+	// Backoff returns the wait before the attempt following attempt n,
+	// counting from 1. ok is false if no more attempts should be made.
+	Backoff(n int, resp *http.Response) (d time.Duration, ok bool)
+}
+
+// DefaultRetryStatus is the set of status codes retried by a BackoffPolicy
+// without RetryStatus.
+var DefaultRetryStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// BackoffPolicy is a RetryPolicy with exponential back-off and jitter.
+type BackoffPolicy struct {
+	// MaxAttempts caps the number of attempts, 0 means until the deadline.
+	MaxAttempts int
+	// BaseDelay is the wait after the first attempt, doubled afterwards.
+	BaseDelay time.Duration
+	// MaxDelay caps a single wait, 0 means no cap.
+	MaxDelay time.Duration
+	// Jitter randomizes every wait by up to this fraction of it, in [0, 1].
+	Jitter float64
+	// RetryStatus lists the retried status codes, nil means DefaultRetryStatus.
+	RetryStatus []int
+	// RetryAfter makes a Retry-After header of the response replace the
+	// computed wait.
+	RetryAfter bool
+}
+
+// DefaultRetryPolicy is used by a Fetcher built without WithRetryPolicy.
+var DefaultRetryPolicy RetryPolicy = &BackoffPolicy{
+	BaseDelay:  time.Second,
+	MaxDelay:   time.Minute,
+	Jitter:     0.2,
+	RetryAfter: true,
+}
+
+// WithRetryPolicy sets the policy GetRawAndDoc retries with.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(f *Fetcher) {
+		f.retryPolicy = p
+	}
+}
+
+// Retryable implements RetryPolicy.
+func (p *BackoffPolicy) Retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	codes := p.RetryStatus
+	if codes == nil {
+		codes = DefaultRetryStatus
+	}
+	for _, c := range codes {
+		if c == resp.StatusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff implements RetryPolicy.
+func (p *BackoffPolicy) Backoff(n int, resp *http.Response) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && n >= p.MaxAttempts {
+		return 0, false
+	}
+	if p.RetryAfter && resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return d, true
+		}
+	}
+	d := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d, true
+}
+
+// parseRetryAfter reads a Retry-After value given in seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// ErrRetriesExhausted is returned when every attempt allowed by the retry
+// policy and the retry timeout failed.
+type ErrRetriesExhausted struct {
+	Attempts int
+	LastErr  error
+}
+
+func (e *ErrRetriesExhausted) Error() string {
+	return fmt.Sprintf("exhtml: giving up after %d attempts: %v", e.Attempts, e.LastErr)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *ErrRetriesExhausted) Unwrap() error {
+	return e.LastErr
+}
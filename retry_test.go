@@ -0,0 +1,88 @@
+package exhtml
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGetRawAndDocRetryStatus(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "<p>ok</p>")
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(WithRetryPolicy(&BackoffPolicy{BaseDelay: time.Millisecond}))
+	u, _ := url.Parse(ts.URL)
+	raw, _, err := f.GetRawAndDoc(u, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "<p>ok</p>" || hits != 3 {
+		t.Errorf("want ok after 3 hits, got: %q after %d", raw, hits)
+	}
+}
+
+func TestGetRawAndDocRetriesExhausted(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(WithRetryPolicy(&BackoffPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	u, _ := url.Parse(ts.URL)
+	_, _, err := f.GetRawAndDoc(u, 5*time.Second)
+	var re *ErrRetriesExhausted
+	if !errors.As(err, &re) {
+		t.Fatalf("want *ErrRetriesExhausted, got: %v", err)
+	}
+	if re.Attempts != 2 || re.LastErr == nil {
+		t.Errorf("want 2 attempts and last error, got: %d, %v", re.Attempts, re.LastErr)
+	}
+}
+
+func TestBackoffPolicy(t *testing.T) {
+	p := &BackoffPolicy{BaseDelay: time.Second, MaxDelay: 3 * time.Second, RetryAfter: true}
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 3 * time.Second},
+		{10, 3 * time.Second},
+	}
+	for _, tc := range tests {
+		if got, _ := p.Backoff(tc.n, nil); got != tc.want {
+			t.Errorf("Backoff(%d): want %v, got: %v", tc.n, tc.want, got)
+		}
+	}
+	resp := &http.Response{Header: http.Header{"Retry-After": {"7"}}}
+	if got, _ := p.Backoff(1, resp); got != 7*time.Second {
+		t.Errorf("Retry-After: want 7s, got: %v", got)
+	}
+	if p.Retryable(&http.Response{StatusCode: http.StatusNotFound}, nil) {
+		t.Errorf("404 should not be retryable")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 10, 9, 0, 0, 0, 0, time.UTC)
+	date := now.Add(30 * time.Second).Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(date, now); !ok || d != 30*time.Second {
+		t.Errorf("want 30s, got: %v, %v", d, ok)
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Errorf("want invalid value rejected")
+	}
+}
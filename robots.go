@@ -0,0 +1,400 @@
+package exhtml
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxRobotsSize is the part of a robots.txt that is parsed, see RFC 9309.
+const maxRobotsSize = 500 << 10
+
+// Robots is a parsed robots.txt.
+type Robots struct {
+	groups []*robotsGroup
+	// Sitemaps lists the Sitemap lines in the order they appear.
+	Sitemaps []string
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+	hasDelay   bool
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// ParseRobots parses a robots.txt. Unknown lines are ignored.
+func ParseRobots(r io.Reader) (*Robots, error) {
+	rb := &Robots{}
+	var g *robotsGroup
+	inAgents := false // consecutive user-agent lines share a group
+	sc := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	sc.Buffer(make([]byte, 0, 4096), maxRobotsSize)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				g = &robotsGroup{}
+				rb.groups = append(rb.groups, g)
+			}
+			g.agents = append(g.agents, strings.ToLower(val))
+			inAgents = true
+			continue
+		case "allow", "disallow":
+			if g != nil && val != "" {
+				g.rules = append(g.rules, robotsRule{allow: key == "allow", pattern: val})
+			}
+		case "crawl-delay":
+			if d, err := strconv.ParseFloat(val, 64); g != nil && err == nil && d >= 0 {
+				g.crawlDelay = time.Duration(d * float64(time.Second))
+				g.hasDelay = true
+			}
+		case "sitemap":
+			rb.Sitemaps = append(rb.Sitemaps, val)
+		}
+		inAgents = false
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.WithMessage(err, "exhtml: ParseRobots")
+	}
+	return rb, nil
+}
+
+// agentName returns the product token of a User-Agent, e.g. "mybot" of
+// "MyBot/1.0 (+https://example.com)".
+func agentName(userAgent string) string {
+	name := strings.ToLower(strings.TrimSpace(userAgent))
+	if i := strings.IndexAny(name, "/ "); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// match returns the rules and crawl delay that apply to userAgent. Groups
+// naming the longest matching agent are merged, "*" is the fallback.
+func (r *Robots) match(userAgent string) ([]robotsRule, *robotsGroup) {
+	name := agentName(userAgent)
+	var rules []robotsRule
+	var delay *robotsGroup
+	best := -1
+	for _, g := range r.groups {
+		for _, a := range g.agents {
+			l := len(a)
+			if a == "*" {
+				l = 0
+			} else if name == "" || !strings.Contains(name, a) {
+				continue
+			}
+			if l > best {
+				rules, delay, best = nil, nil, l
+			}
+			if l == best {
+				rules = append(rules, g.rules...)
+				if delay == nil && g.hasDelay {
+					delay = g
+				}
+			}
+		}
+	}
+	return rules, delay
+}
+
+// Allowed reports whether userAgent may fetch path, which may carry a query.
+// The longest matching rule wins, Allow wins a tie.
+func (r *Robots) Allowed(userAgent, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	rules, _ := r.match(userAgent)
+	allowed, best := true, -1
+	for _, rule := range rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if l := len(rule.pattern); l > best || (l == best && rule.allow) {
+			allowed, best = rule.allow, l
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the Crawl-delay of the group applying to userAgent.
+func (r *Robots) CrawlDelay(userAgent string) (time.Duration, bool) {
+	_, g := r.match(userAgent)
+	if g == nil {
+		return 0, false
+	}
+	return g.crawlDelay, true
+}
+
+// robotsMatch matches path against a rule pattern with * wildcards and an
+// optional $ end anchor.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+	for i, p := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, p)
+		}
+		j := strings.Index(rest, p)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(p):]
+	}
+	return true
+}
+
+// allowAll and disallowAll stand in for robots.txt files that are missing
+// or unavailable.
+var (
+	allowAll    = &Robots{}
+	disallowAll = &Robots{groups: []*robotsGroup{{
+		agents: []string{"*"},
+		rules:  []robotsRule{{pattern: "/"}},
+	}}}
+)
+
+// RobotsChecker fetches, caches and checks the robots.txt of every host.
+type RobotsChecker struct {
+	fetcher   *Fetcher
+	userAgent string
+	// TTL is how long a robots.txt is cached, 24 hours by default.
+	TTL time.Duration
+	// ErrorTTL is how long a server error (5xx) on robots.txt is cached,
+	// 1 minute by default.
+	ErrorTTL time.Duration
+
+	mu       sync.Mutex
+	cache    map[string]robotsEntry
+	inflight map[string]*robotsCall
+}
+
+// robotsCall is a robots.txt fetch shared by the concurrent requests to a
+// host.
+type robotsCall struct {
+	done   chan struct{}
+	robots *Robots
+	err    error
+}
+
+type robotsEntry struct {
+	robots  *Robots
+	expires time.Time
+}
+
+// NewRobotsChecker returns a RobotsChecker for userAgent fetching with f,
+// or with the default fetcher if f is nil.
+func NewRobotsChecker(f *Fetcher, userAgent string) *RobotsChecker {
+	if f == nil {
+		f = defaultFetcher
+	}
+	return &RobotsChecker{
+		fetcher:   f,
+		userAgent: userAgent,
+		TTL:       24 * time.Hour,
+		ErrorTTL:  time.Minute,
+		cache:     map[string]robotsEntry{},
+		inflight:  map[string]*robotsCall{},
+	}
+}
+
+// WithRobots makes the Fetcher refuse URLs disallowed by c with
+// a RobotsDisallowedError.
+func WithRobots(c *RobotsChecker) Option {
+	return func(f *Fetcher) {
+		f.robots = c
+	}
+}
+
+// WithRobotsTxt is like WithRobots with a checker for userAgent that
+// fetches robots.txt through the Fetcher being configured.
+func WithRobotsTxt(userAgent string) Option {
+	return func(f *Fetcher) {
+		f.robots = NewRobotsChecker(f, userAgent)
+	}
+}
+
+// Robots returns the cached or freshly fetched robots.txt of u's host.
+// A missing robots.txt (4xx) allows everything, a server error (5xx)
+// disallows everything for ErrorTTL. Concurrent calls for a host share one
+// fetch.
+func (c *RobotsChecker) Robots(ctx context.Context, u *url.URL) (*Robots, error) {
+	key := u.Scheme + "://" + strings.ToLower(u.Host)
+	for {
+		c.mu.Lock()
+		if e, ok := c.cache[key]; ok && time.Now().Before(e.expires) {
+			c.mu.Unlock()
+			return e.robots, nil
+		}
+		call, ok := c.inflight[key]
+		if !ok {
+			break // with c.mu held
+		}
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// the fetch failed on the context of its caller, not on ours
+		if ctxErr(call.err) && ctx.Err() == nil {
+			continue
+		}
+		return call.robots, call.err
+	}
+	call := &robotsCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.robots, call.err = c.fetch(ctx, key+"/robots.txt")
+	c.mu.Lock()
+	if call.err == nil {
+		ttl := c.TTL
+		if call.robots == disallowAll {
+			ttl = c.ErrorTTL
+		}
+		c.cache[key] = robotsEntry{robots: call.robots, expires: time.Now().Add(ttl)}
+	}
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(call.done)
+	return call.robots, call.err
+}
+
+// ctxErr reports whether err comes from a canceled or expired context.
+func ctxErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// robotsFetchKey marks the context of a robots.txt request, whose
+// redirects are not checked against robots.txt.
+type robotsFetchKey struct{}
+
+// fetch requests the robots.txt at src.
+func (c *RobotsChecker) fetch(ctx context.Context, src string) (*Robots, error) {
+	ctx = context.WithValue(ctx, robotsFetchKey{}, true)
+	resp, err := c.fetcher.request(ctx, src)
+	if err != nil {
+		return nil, requestError(src, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		rb, err := ParseRobots(resp.Body)
+		if err != nil {
+			return nil, &ParseError{URL: src, Err: err}
+		}
+		return rb, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return allowAll, nil
+	case resp.StatusCode >= 500:
+		return disallowAll, nil
+	default:
+		return nil, newHTTPStatusError(src, resp)
+	}
+}
+
+// Allowed reports whether robots.txt allows fetching rawurl.
+func (c *RobotsChecker) Allowed(rawurl string) (bool, error) {
+	return c.AllowedContext(context.Background(), rawurl)
+}
+
+// AllowedContext is like Allowed with a cancelable ctx.
+func (c *RobotsChecker) AllowedContext(ctx context.Context, rawurl string) (bool, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return true, nil
+	}
+	rb, err := c.Robots(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	return rb.Allowed(c.userAgent, u.RequestURI()), nil
+}
+
+// CrawlDelay returns the Crawl-delay robots.txt sets for rawurl's host.
+func (c *RobotsChecker) CrawlDelay(ctx context.Context, rawurl string) (time.Duration, bool, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return 0, false, err
+	}
+	rb, err := c.Robots(ctx, u)
+	if err != nil {
+		return 0, false, err
+	}
+	d, ok := rb.CrawlDelay(c.userAgent)
+	return d, ok, nil
+}
+
+// checkRobots returns a RobotsDisallowedError if req may not be sent.
+func (f *Fetcher) checkRobots(ctx context.Context, req *http.Request) error {
+	if f.robots == nil || req.URL.Path == "/robots.txt" || ctx.Value(robotsFetchKey{}) != nil {
+		return nil
+	}
+	ok, err := f.robots.AllowedContext(ctx, req.URL.String())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &RobotsDisallowedError{URL: req.URL.String()}
+	}
+	return nil
+}
+
+// robotsCheckRedirect wraps the CheckRedirect of f's client to check every
+// redirect hop against robots.txt.
+func (f *Fetcher) robotsCheckRedirect() {
+	next := f.client.CheckRedirect
+	f.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := f.checkRobots(req.Context(), req); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
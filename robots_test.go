@@ -0,0 +1,247 @@
+package exhtml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRobots = `
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public$
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: exbot
+User-agent: otherbot
+Disallow: /
+Allow: /news/
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	rb, err := ParseRobots(strings.NewReader(testRobots))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		agent, path string
+		want        bool
+	}{
+		{"Mozilla/5.0", "/", true},
+		{"Mozilla/5.0", "/private/a", false},
+		{"Mozilla/5.0", "/private/public", true},
+		{"Mozilla/5.0", "/private/public/more", false},
+		{"Mozilla/5.0", "/doc.pdf", false},
+		{"Mozilla/5.0", "/doc.pdf?x=1", true},
+		{"ExBot/1.0", "/private/a", false},
+		{"ExBot/1.0", "/about", false},
+		{"ExBot/1.0", "/news/1", true},
+		{"otherbot", "/robots.txt", true},
+	}
+	for _, tc := range tests {
+		if got := rb.Allowed(tc.agent, tc.path); got != tc.want {
+			t.Errorf("%s %s: want %v, got: %v", tc.agent, tc.path, tc.want, got)
+		}
+	}
+	if d, ok := rb.CrawlDelay("Mozilla/5.0"); !ok || d != 2*time.Second {
+		t.Errorf("want crawl delay 2s, got: %v, %v", d, ok)
+	}
+	if _, ok := rb.CrawlDelay("exbot"); ok {
+		t.Errorf("want no crawl delay for exbot")
+	}
+	if len(rb.Sitemaps) != 1 || rb.Sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("want 1 sitemap, got: %v", rb.Sitemaps)
+	}
+}
+
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/a", "/abc", true},
+		{"/a$", "/abc", false},
+		{"/a*c", "/abbbc", true},
+		{"/a*c$", "/abbbcd", false},
+		{"/*/b*", "/x/y/b", true},
+		{"*", "/", true},
+	}
+	for _, tc := range tests {
+		if got := robotsMatch(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("%s %s: want %v, got: %v", tc.pattern, tc.path, tc.want, got)
+		}
+	}
+}
+
+func TestFetcherRobots(t *testing.T) {
+	robotsHits := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		robotsHits++
+		fmt.Fprint(w, testRobots)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<p>ok</p>")
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	f := NewFetcher(WithRobotsTxt("exbot"))
+	if _, err := f.ExtractLinks(ts.URL + "/news/1"); err != nil {
+		t.Errorf("want /news/1 allowed, got: %v", err)
+	}
+	_, err := f.ExtractLinks(ts.URL + "/about")
+	var rd *RobotsDisallowedError
+	if !errors.As(err, &rd) {
+		t.Errorf("want *RobotsDisallowedError, got: %v", err)
+	}
+	if robotsHits != 1 {
+		t.Errorf("want robots.txt fetched once, got: %d", robotsHits)
+	}
+}
+
+func TestRobotsCheckerStatus(t *testing.T) {
+	status := http.StatusNotFound
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	c := NewRobotsChecker(nil, "exbot")
+	if ok, err := c.Allowed(ts.URL + "/a"); err != nil || !ok {
+		t.Errorf("404 robots.txt: want allowed, got: %v, %v", ok, err)
+	}
+	status = http.StatusServiceUnavailable
+	c = NewRobotsChecker(nil, "exbot")
+	if ok, err := c.Allowed(ts.URL + "/a"); err != nil || ok {
+		t.Errorf("503 robots.txt: want disallowed, got: %v, %v", ok, err)
+	}
+}
+
+func TestFetcherRobotsRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testRobots)
+	})
+	mux.HandleFunc("/news/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/about", http.StatusFound)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<p>ok</p>")
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	f := NewFetcher(WithRobotsTxt("exbot"))
+	_, err := f.ExtractLinks(ts.URL + "/news/moved")
+	var rd *RobotsDisallowedError
+	if !errors.As(err, &rd) {
+		t.Errorf("want *RobotsDisallowedError, got: %v", err)
+	}
+}
+
+func TestRobotsCheckerConcurrent(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, testRobots)
+	}))
+	defer ts.Close()
+
+	c := NewRobotsChecker(nil, "exbot")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Allowed(ts.URL + "/news/1"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if hits != 1 {
+		t.Errorf("want robots.txt fetched once, got: %d", hits)
+	}
+}
+
+func TestFetcherRobotsTxtRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<p>ok</p>")
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewFetcher(WithRobotsTxt("exbot")).ExtractLinks(ts.URL + "/news/1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetch through a redirected robots.txt hangs")
+	}
+}
+
+func TestRobotsCheckerCanceledLeader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(w, testRobots)
+	}))
+	defer ts.Close()
+
+	c := NewRobotsChecker(nil, "exbot")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	leader := make(chan error, 1)
+	go func() {
+		_, err := c.AllowedContext(ctx, ts.URL+"/news/1")
+		leader <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if ok, err := c.Allowed(ts.URL + "/news/1"); err != nil || !ok {
+		t.Errorf("want allowed despite the canceled leader, got: %v, %v", ok, err)
+	}
+	if err := <-leader; err == nil {
+		t.Errorf("want the leader canceled")
+	}
+}
+
+func TestRobotsCheckerErrorTTL(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	c := NewRobotsChecker(nil, "exbot")
+	c.ErrorTTL = 10 * time.Millisecond
+	if ok, _ := c.Allowed(ts.URL + "/a"); ok {
+		t.Errorf("503 robots.txt: want disallowed")
+	}
+	status = http.StatusNotFound
+	time.Sleep(20 * time.Millisecond)
+	if ok, err := c.Allowed(ts.URL + "/a"); err != nil || !ok {
+		t.Errorf("want the 503 forgotten after ErrorTTL, got: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,183 @@
+package exhtml
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selection is a list of nodes queried in chains, such as
+//
+//	NewSelection(doc).Find(Class("article")).Find(Tag("p")).Text()
+//
+// The results of ElementsByTag and the other helpers can be wrapped
+// directly with NewSelection.
+type Selection struct {
+	Nodes []*html.Node
+}
+
+// NewSelection returns a Selection of nodes.
+func NewSelection(nodes ...*html.Node) *Selection {
+	return &Selection{Nodes: nodes}
+}
+
+// Len returns the number of nodes of s.
+func (s *Selection) Len() int {
+	return len(s.Nodes)
+}
+
+// collect returns a Selection of the nodes f returns for each node of s,
+// without duplicates.
+func (s *Selection) collect(f func(n *html.Node) []*html.Node) *Selection {
+	var nodes []*html.Node
+	seen := map[*html.Node]bool{}
+	for _, n := range s.Nodes {
+		for _, c := range f(n) {
+			if c != nil && !seen[c] {
+				seen[c] = true
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	return &Selection{Nodes: nodes}
+}
+
+// Find returns the descendants of the nodes of s matched by m.
+func (s *Selection) Find(m Matcher) *Selection {
+	return s.collect(func(n *html.Node) []*html.Node {
+		var nodes []*html.Node
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			nodes = append(nodes, FindAll(c, m)...)
+		}
+		return nodes
+	})
+}
+
+// Filter returns the nodes of s matched by m.
+func (s *Selection) Filter(m Matcher) *Selection {
+	var nodes []*html.Node
+	for _, n := range s.Nodes {
+		if m(n) {
+			nodes = append(nodes, n)
+		}
+	}
+	return &Selection{Nodes: nodes}
+}
+
+// Children returns the child elements of the nodes of s.
+func (s *Selection) Children() *Selection {
+	return s.collect(func(n *html.Node) []*html.Node {
+		var nodes []*html.Node
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				nodes = append(nodes, c)
+			}
+		}
+		return nodes
+	})
+}
+
+// Parent returns the parent elements of the nodes of s.
+func (s *Selection) Parent() *Selection {
+	return s.collect(func(n *html.Node) []*html.Node {
+		return []*html.Node{parentElement(n)}
+	})
+}
+
+// Next returns the next sibling elements of the nodes of s.
+func (s *Selection) Next() *Selection {
+	return s.collect(func(n *html.Node) []*html.Node {
+		return []*html.Node{nextElement(n)}
+	})
+}
+
+// Prev returns the previous sibling elements of the nodes of s.
+func (s *Selection) Prev() *Selection {
+	return s.collect(func(n *html.Node) []*html.Node {
+		return []*html.Node{prevElement(n)}
+	})
+}
+
+// First returns the first node of s.
+func (s *Selection) First() *Selection {
+	return s.Eq(0)
+}
+
+// Eq returns the node of s at index i, counted from the end if i is
+// negative, or an empty Selection if there is none.
+func (s *Selection) Eq(i int) *Selection {
+	if i < 0 {
+		i += len(s.Nodes)
+	}
+	if i < 0 || i >= len(s.Nodes) {
+		return &Selection{}
+	}
+	return &Selection{Nodes: []*html.Node{s.Nodes[i]}}
+}
+
+// Each calls f with the index and a Selection of each node of s, and
+// returns s.
+func (s *Selection) Each(f func(i int, s *Selection)) *Selection {
+	for i, n := range s.Nodes {
+		f(i, NewSelection(n))
+	}
+	return s
+}
+
+// Map returns the values f returns with the index and a Selection of each
+// node of s.
+func (s *Selection) Map(f func(i int, s *Selection) string) []string {
+	var vs []string
+	for i, n := range s.Nodes {
+		vs = append(vs, f(i, NewSelection(n)))
+	}
+	return vs
+}
+
+// Attr returns the value of the attribute key of the first node of s and
+// whether it exists.
+func (s *Selection) Attr(key string) (string, bool) {
+	if len(s.Nodes) == 0 {
+		return "", false
+	}
+	for _, a := range s.Nodes[0].Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// Text returns the text of the nodes of s and their descendants.
+func (s *Selection) Text() string {
+	var b strings.Builder
+	for _, n := range s.Nodes {
+		b.WriteString(nodeText(n))
+	}
+	return b.String()
+}
+
+// HTML returns the rendered content of the first node of s.
+func (s *Selection) HTML() (string, error) {
+	if len(s.Nodes) == 0 {
+		return "", nil
+	}
+	var b bytes.Buffer
+	for c := s.Nodes[0].FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// Remove removes the nodes of s from the document and returns s.
+func (s *Selection) Remove() *Selection {
+	for _, n := range s.Nodes {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+	return s
+}
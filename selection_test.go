@@ -0,0 +1,70 @@
+package exhtml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSelection(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><body>
+<div class="article"><h1>Title</h1><p id="p1">one</p><p id="p2" class="ad">two</p><p id="p3">three <b>3</b></p></div>
+<div class="article"><p id="p4">four</p></div>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSelection(doc)
+	ps := s.Find(Class("article")).Find(Tag("p"))
+	if ps.Len() != 4 {
+		t.Fatalf("want 4 paragraphs, got %d", ps.Len())
+	}
+	if got := ps.Filter(Not(Class("ad"))).Text(); got != "onethree 3four" {
+		t.Errorf("want text of p1 p3 p4, got %q", got)
+	}
+	ids := ps.Map(func(i int, s *Selection) string {
+		id, _ := s.Attr("id")
+		return id
+	})
+	if !reflect.DeepEqual(ids, []string{"p1", "p2", "p3", "p4"}) {
+		t.Errorf("unexpected ids %v", ids)
+	}
+	if got := ps.Parent().Len(); got != 2 {
+		t.Errorf("want 2 parents, got %d", got)
+	}
+	if got := s.Find(Tag("h1")).Next().Text(); got != "one" {
+		t.Errorf("want next of h1, got %q", got)
+	}
+	if got := ps.Eq(1).Prev().Text(); got != "one" {
+		t.Errorf("want prev of p2, got %q", got)
+	}
+	if got := ps.Eq(-1).Text(); got != "four" {
+		t.Errorf("want last p, got %q", got)
+	}
+	if got := ps.Eq(9).Len(); got != 0 {
+		t.Errorf("want empty selection, got %d", got)
+	}
+	if got := ps.First().Parent().Children().Len(); got != 4 {
+		t.Errorf("want 4 children, got %d", got)
+	}
+	if got, err := ps.Eq(2).HTML(); err != nil || got != "three <b>3</b>" {
+		t.Errorf("want html of p3, got %q %v", got, err)
+	}
+	if _, ok := ps.First().Attr("class"); ok {
+		t.Errorf("want no class on p1")
+	}
+	var n int
+	NewSelection(ElementsByTag(doc, "p")...).Each(func(i int, s *Selection) {
+		n += i
+	})
+	if n != 6 {
+		t.Errorf("want Each over 4 nodes, got index sum %d", n)
+	}
+
+	ps.Filter(Class("ad")).Remove()
+	if got := s.Find(Tag("p")).Len(); got != 3 {
+		t.Errorf("want 3 paragraphs after remove, got %d", got)
+	}
+}
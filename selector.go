@@ -0,0 +1,686 @@
+package exhtml
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// Selector is a compiled group of CSS Selectors Level 3, such as
+// "article.story > div.body p:not(.ad)".
+//
+// It supports type, universal, class, id and attribute selectors with the
+// = ~= |= ^= $= *= operators, the descendant, child, next sibling and
+// subsequent sibling combinators, and the pseudo-classes :root, :empty,
+// :first-child, :last-child, :only-child, :first-of-type, :last-of-type,
+// :only-of-type, :nth-child(), :nth-last-child(), :nth-of-type(),
+// :nth-last-of-type(), :not() and :contains(text).
+type Selector struct {
+	src    string
+	groups []complexSelector
+}
+
+// complexSelector is a chain of compound selectors, the subject first.
+// combinators[i] joins compounds[i] to compounds[i+1] on its left.
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []byte
+}
+
+type compoundSelector struct {
+	tag   string
+	preds []func(*html.Node) bool
+}
+
+// CompileSelector parses a CSS selector group.
+func CompileSelector(selector string) (*Selector, error) {
+	p := &selectorParser{s: selector}
+	groups, err := p.parseGroup(false)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "exhtml: CompileSelector %q", selector)
+	}
+	return &Selector{src: selector, groups: groups}, nil
+}
+
+// MustCompileSelector is like CompileSelector but panics if selector
+// cannot be parsed.
+func MustCompileSelector(selector string) *Selector {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the source of s.
+func (s *Selector) String() string {
+	return s.src
+}
+
+// Match reports whether the element n matches s.
+func (s *Selector) Match(n *html.Node) bool {
+	return matchGroups(s.groups, n)
+}
+
+// QueryAll returns the descendants of n matching s in document order.
+func (s *Selector) QueryAll(n *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		ForEachNode(c, func(d *html.Node) {
+			if s.Match(d) {
+				nodes = append(nodes, d)
+			}
+		}, nil)
+	}
+	return nodes
+}
+
+// Query returns the first descendant of n matching s, or nil.
+func (s *Selector) Query(n *html.Node) *html.Node {
+	var found *html.Node
+	var walk func(*html.Node) bool
+	walk = func(n *html.Node) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if s.Match(c) {
+				found = c
+				return true
+			}
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(n)
+	return found
+}
+
+// QueryAll returns the descendants of n matching the CSS selector in
+// document order.
+func QueryAll(n *html.Node, selector string) ([]*html.Node, error) {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.QueryAll(n), nil
+}
+
+// Query returns the first descendant of n matching the CSS selector, or
+// nil.
+func Query(n *html.Node, selector string) (*html.Node, error) {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.Query(n), nil
+}
+
+func matchGroups(groups []complexSelector, n *html.Node) bool {
+	if n == nil || n.Type != html.ElementNode {
+		return false
+	}
+	for i := range groups {
+		if groups[i].match(n, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *complexSelector) match(n *html.Node, i int) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	}
+	if i == len(c.compounds)-1 {
+		return true
+	}
+	switch c.combinators[i] {
+	case '>':
+		p := parentElement(n)
+		return p != nil && c.match(p, i+1)
+	case '+':
+		p := prevElement(n)
+		return p != nil && c.match(p, i+1)
+	case '~':
+		for p := prevElement(n); p != nil; p = prevElement(p) {
+			if c.match(p, i+1) {
+				return true
+			}
+		}
+	default:
+		for p := parentElement(n); p != nil; p = parentElement(p) {
+			if c.match(p, i+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *compoundSelector) match(n *html.Node) bool {
+	if n.Type != html.ElementNode || (c.tag != "" && c.tag != "*" && c.tag != n.Data) {
+		return false
+	}
+	for _, pred := range c.preds {
+		if !pred(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func parentElement(n *html.Node) *html.Node {
+	if p := n.Parent; p != nil && p.Type == html.ElementNode {
+		return p
+	}
+	return nil
+}
+
+func prevElement(n *html.Node) *html.Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func nextElement(n *html.Node) *html.Node {
+	for p := n.NextSibling; p != nil; p = p.NextSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+// elementIndex returns the 1-based position of n among its element
+// siblings, or among those of its type, counted from the end if last.
+func elementIndex(n *html.Node, ofType, last bool) int {
+	i := 1
+	sibling := prevElement
+	if last {
+		sibling = nextElement
+	}
+	for s := sibling(n); s != nil; s = sibling(s) {
+		if !ofType || s.Data == n.Data {
+			i++
+		}
+	}
+	return i
+}
+
+type selectorParser struct {
+	s   string
+	pos int
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("offset %d: "+format, append([]interface{}{p.pos}, args...)...)
+}
+
+func (p *selectorParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *selectorParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// skipSpace skips white space and reports whether there was any.
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\n\r\f", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// parseGroup parses a comma separated list of complex selectors, up to a
+// closing parenthesis if nested.
+func (p *selectorParser) parseGroup(nested bool) ([]complexSelector, error) {
+	var groups []complexSelector
+	for {
+		p.skipSpace()
+		c, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, c)
+		p.skipSpace()
+		switch {
+		case p.eof() && !nested:
+			return groups, nil
+		case p.peek() == ')' && nested:
+			return groups, nil
+		case p.peek() == ',':
+			p.pos++
+		case p.eof():
+			return nil, p.errorf("missing )")
+		default:
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+	}
+}
+
+func (p *selectorParser) parseComplex() (complexSelector, error) {
+	var c complexSelector
+	cs, err := p.parseCompound()
+	if err != nil {
+		return c, err
+	}
+	compounds := []compoundSelector{cs}
+	var combinators []byte
+	for {
+		space := p.skipSpace()
+		if p.eof() || p.peek() == ',' || p.peek() == ')' {
+			break
+		}
+		comb := byte(' ')
+		if b := p.peek(); b == '>' || b == '+' || b == '~' {
+			comb = b
+			p.pos++
+			p.skipSpace()
+		} else if !space {
+			return c, p.errorf("unexpected %q", b)
+		}
+		cs, err := p.parseCompound()
+		if err != nil {
+			return c, err
+		}
+		compounds = append(compounds, cs)
+		combinators = append(combinators, comb)
+	}
+	// match right to left
+	for i, j := 0, len(compounds)-1; i < j; i, j = i+1, j-1 {
+		compounds[i], compounds[j] = compounds[j], compounds[i]
+	}
+	for i, j := 0, len(combinators)-1; i < j; i, j = i+1, j-1 {
+		combinators[i], combinators[j] = combinators[j], combinators[i]
+	}
+	c.compounds, c.combinators = compounds, combinators
+	return c, nil
+}
+
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	var c compoundSelector
+	start := p.pos
+	if p.peek() == '*' {
+		p.pos++
+		c.tag = "*"
+	} else if p.identStart() {
+		c.tag = strings.ToLower(p.name())
+	}
+	for {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			if !isNameByte(p.peek()) {
+				return c, p.errorf("expected id")
+			}
+			id := p.name()
+			c.preds = append(c.preds, func(n *html.Node) bool { return attr(n, "id") == id })
+		case '.':
+			p.pos++
+			if !p.identStart() {
+				return c, p.errorf("expected class")
+			}
+			class := p.name()
+			c.preds = append(c.preds, func(n *html.Node) bool { return HasClass(n, class) })
+		case '[':
+			pred, err := p.parseAttr()
+			if err != nil {
+				return c, err
+			}
+			c.preds = append(c.preds, pred)
+		case ':':
+			pred, err := p.parsePseudo()
+			if err != nil {
+				return c, err
+			}
+			c.preds = append(c.preds, pred)
+		default:
+			if p.pos == start {
+				if p.eof() {
+					return c, p.errorf("expected selector")
+				}
+				return c, p.errorf("unexpected %q", p.peek())
+			}
+			return c, nil
+		}
+	}
+}
+
+func (p *selectorParser) parseAttr() (func(*html.Node) bool, error) {
+	p.pos++ // [
+	p.skipSpace()
+	if !p.identStart() {
+		return nil, p.errorf("expected attribute name")
+	}
+	key := strings.ToLower(p.name())
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		return func(n *html.Node) bool {
+			for _, a := range n.Attr {
+				if a.Namespace == "" && a.Key == key {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	var op string
+	switch {
+	case p.peek() == '=':
+		op = "="
+		p.pos++
+	case strings.HasPrefix(p.s[p.pos:], "~="), strings.HasPrefix(p.s[p.pos:], "|="),
+		strings.HasPrefix(p.s[p.pos:], "^="), strings.HasPrefix(p.s[p.pos:], "$="),
+		strings.HasPrefix(p.s[p.pos:], "*="):
+		op = p.s[p.pos : p.pos+2]
+		p.pos += 2
+	default:
+		return nil, p.errorf("unexpected %q in attribute selector", p.peek())
+	}
+	p.skipSpace()
+	var val string
+	switch {
+	case p.peek() == '"' || p.peek() == '\'':
+		s, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		val = s
+	case isNameByte(p.peek()):
+		val = p.name()
+	default:
+		return nil, p.errorf("expected attribute value")
+	}
+	p.skipSpace()
+	fold := false
+	if b := p.peek(); b == 'i' || b == 'I' {
+		fold = true
+		p.pos++
+		p.skipSpace()
+	}
+	if p.peek() != ']' {
+		return nil, p.errorf("missing ]")
+	}
+	p.pos++
+	if fold {
+		val = strings.ToLower(val)
+	}
+	return func(n *html.Node) bool {
+		for _, a := range n.Attr {
+			if a.Namespace != "" || a.Key != key {
+				continue
+			}
+			v := a.Val
+			if fold {
+				v = strings.ToLower(v)
+			}
+			return attrMatch(op, v, val)
+		}
+		return false
+	}, nil
+}
+
+func attrMatch(op, v, val string) bool {
+	switch op {
+	case "=":
+		return v == val
+	case "~=":
+		if val == "" || strings.ContainsAny(val, " \t\n\r\f") {
+			return false
+		}
+		for _, f := range strings.Fields(v) {
+			if f == val {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return v == val || strings.HasPrefix(v, val+"-")
+	case "^=":
+		return val != "" && strings.HasPrefix(v, val)
+	case "$=":
+		return val != "" && strings.HasSuffix(v, val)
+	case "*=":
+		return val != "" && strings.Contains(v, val)
+	}
+	return false
+}
+
+func (p *selectorParser) parsePseudo() (func(*html.Node) bool, error) {
+	p.pos++ // :
+	if p.peek() == ':' {
+		return nil, p.errorf("pseudo-elements are not supported")
+	}
+	if !p.identStart() {
+		return nil, p.errorf("expected pseudo-class")
+	}
+	name := strings.ToLower(p.name())
+	if p.peek() != '(' {
+		switch name {
+		case "root":
+			return func(n *html.Node) bool {
+				return n.Parent != nil && n.Parent.Type == html.DocumentNode
+			}, nil
+		case "empty":
+			return func(n *html.Node) bool {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == html.ElementNode || c.Type == html.TextNode {
+						return false
+					}
+				}
+				return true
+			}, nil
+		case "first-child":
+			return nthPred(0, 1, false, false), nil
+		case "last-child":
+			return nthPred(0, 1, false, true), nil
+		case "only-child":
+			return andPred(nthPred(0, 1, false, false), nthPred(0, 1, false, true)), nil
+		case "first-of-type":
+			return nthPred(0, 1, true, false), nil
+		case "last-of-type":
+			return nthPred(0, 1, true, true), nil
+		case "only-of-type":
+			return andPred(nthPred(0, 1, true, false), nthPred(0, 1, true, true)), nil
+		}
+		return nil, p.errorf("unsupported pseudo-class :%s", name)
+	}
+	p.pos++ // (
+	p.skipSpace()
+	var pred func(*html.Node) bool
+	switch name {
+	case "not":
+		groups, err := p.parseGroup(true)
+		if err != nil {
+			return nil, err
+		}
+		pred = func(n *html.Node) bool { return !matchGroups(groups, n) }
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		end := strings.IndexByte(p.s[p.pos:], ')')
+		if end < 0 {
+			return nil, p.errorf("missing )")
+		}
+		a, b, err := parseNth(p.s[p.pos : p.pos+end])
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.pos += end
+		pred = nthPred(a, b, strings.HasSuffix(name, "of-type"), strings.Contains(name, "last"))
+	case "contains":
+		var text string
+		if b := p.peek(); b == '"' || b == '\'' {
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			text = s
+		} else {
+			end := strings.IndexByte(p.s[p.pos:], ')')
+			if end < 0 {
+				return nil, p.errorf("missing )")
+			}
+			text = strings.TrimSpace(p.s[p.pos : p.pos+end])
+			p.pos += end
+		}
+		pred = func(n *html.Node) bool { return strings.Contains(nodeText(n), text) }
+	default:
+		return nil, p.errorf("unsupported pseudo-class :%s()", name)
+	}
+	p.skipSpace()
+	if p.peek() != ')' {
+		return nil, p.errorf("missing )")
+	}
+	p.pos++
+	return pred, nil
+}
+
+func andPred(preds ...func(*html.Node) bool) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		for _, pred := range preds {
+			if !pred(n) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// nthPred matches the elements at a position an+b for some n >= 0.
+func nthPred(a, b int, ofType, last bool) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		i := elementIndex(n, ofType, last)
+		if a == 0 {
+			return i == b
+		}
+		return (i-b)/a >= 0 && (i-b)%a == 0
+	}
+}
+
+// parseNth parses the an+b argument of the :nth- pseudo-classes.
+func parseNth(s string) (a, b int, err error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	case "":
+		return 0, 0, errors.New("empty nth argument")
+	}
+	i := strings.IndexByte(s, 'n')
+	if i < 0 {
+		b, err = strconv.Atoi(s)
+		return 0, b, err
+	}
+	switch s[:i] {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(s[:i]); err != nil {
+			return 0, 0, errors.Errorf("bad nth argument %q", s)
+		}
+	}
+	if rest := s[i+1:]; rest != "" {
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, errors.Errorf("bad nth argument %q", s)
+		}
+		if b, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, errors.Errorf("bad nth argument %q", s)
+		}
+	}
+	return a, b, nil
+}
+
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '_' || c >= 0x80 || c == '\\'
+}
+
+// identStart reports whether an identifier starts at the current position.
+func (p *selectorParser) identStart() bool {
+	s := p.s[p.pos:]
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	c := s[0]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80 || c == '\\' || c == '-'
+}
+
+// name reads a sequence of name characters, unescaping backslashes.
+func (p *selectorParser) name() string {
+	var b strings.Builder
+	for !p.eof() && isNameByte(p.peek()) {
+		if p.peek() == '\\' {
+			p.pos++
+			b.WriteString(p.escape())
+			continue
+		}
+		b.WriteByte(p.s[p.pos])
+		p.pos++
+	}
+	return b.String()
+}
+
+// escape reads what follows a backslash: up to 6 hex digits and an
+// optional white space, or any single character.
+func (p *selectorParser) escape() string {
+	start := p.pos
+	for p.pos < len(p.s) && p.pos-start < 6 && strings.IndexByte("0123456789abcdefABCDEF", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	if p.pos > start {
+		r, _ := strconv.ParseUint(p.s[start:p.pos], 16, 32)
+		if !p.eof() && strings.IndexByte(" \t\n\r\f", p.peek()) >= 0 {
+			p.pos++
+		}
+		if r == 0 || r > utf8.MaxRune {
+			r = utf8.RuneError
+		}
+		return string(rune(r))
+	}
+	if p.eof() {
+		return ""
+	}
+	_, size := utf8.DecodeRuneInString(p.s[p.pos:])
+	p.pos += size
+	return p.s[p.pos-size : p.pos]
+}
+
+// str reads a quoted string.
+func (p *selectorParser) str() (string, error) {
+	q := p.peek()
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		switch c {
+		case q:
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			p.pos++
+			b.WriteString(p.escape())
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
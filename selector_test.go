@@ -0,0 +1,99 @@
+package exhtml
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+var testSelectorDoc = `<html><body>
+<article class="story main" id="s1" lang="en-US">
+  <h1 data-kind="headline">Title</h1>
+  <div class="body">
+    <p id="p1">one</p>
+    <p id="p2" class="ad">two</p>
+    <div><p id="p3">three <b>bold</b></p></div>
+    <span id="sp1"></span>
+    <p id="p4" title="a b">four</p>
+  </div>
+</article>
+<article class="story" lang="zh-Hant">
+  <a id="a1" href="https://www.bbc.com/news/1.html">1</a>
+  <a id="a2" href="/news/2.htm">2</a>
+</article>
+</body></html>`
+
+func selectorIDs(t *testing.T, doc *html.Node, sel string) string {
+	t.Helper()
+	nodes, err := QueryAll(doc, sel)
+	if err != nil {
+		t.Fatalf("%s: %v", sel, err)
+	}
+	var ids []string
+	for _, n := range nodes {
+		id := attr(n, "id")
+		if id == "" {
+			id = n.Data
+		}
+		ids = append(ids, id)
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestQueryAll(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testSelectorDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := map[string]string{
+		"article.story > div.body p:not(.ad)":   "p1 p3 p4",
+		"article.story.main p":                  "p1 p2 p3 p4",
+		"#s1 > div > p":                         "p1 p2 p4",
+		"div.body > p:first-of-type":            "p1",
+		"div.body > p:last-of-type":             "p4",
+		"div.body > :nth-child(2)":              "p2",
+		"div.body > :nth-child(odd)":            "p1 div p4",
+		"div.body > p:nth-of-type(2n+1)":        "p1 p4",
+		"div.body > :nth-last-child(-n+2)":      "sp1 p4",
+		"div.body > :nth-child(3) ~ p":          "p4",
+		"h1 + div":                              "div",
+		"p + p":                                 "p2",
+		"span:empty":                            "sp1",
+		"p:contains(three)":                     "p3",
+		`p:contains("fo")`:                      "p4",
+		"[data-kind]":                           "h1",
+		`[data-kind="headline"]`:                "h1",
+		`[title~=b]`:                            "p4",
+		`[lang|=en]`:                            "s1",
+		`a[href^="https://"]`:                   "a1",
+		`a[href$=".htm"]`:                       "a2",
+		`a[href*=news]`:                         "a1 a2",
+		`a[HREF$=".HTML" i]`:                    "a1",
+		"h1, #p3 b":                             "h1 b",
+		"html:root > body > article:only-child": "",
+		"b:only-child":                          "b",
+		"*:not(p, div, b) > p":                  "",
+	}
+	for sel, want := range tcs {
+		if got := selectorIDs(t, doc, sel); got != want {
+			t.Errorf("%s: want %q, got %q", sel, want, got)
+		}
+	}
+
+	n, err := Query(doc, "article p.ad")
+	if err != nil || n == nil || attr(n, "id") != "p2" {
+		t.Errorf("want p2, got %v %v", n, err)
+	}
+	if n, err := Query(doc, "table"); n != nil || err != nil {
+		t.Errorf("want nil, got %v %v", n, err)
+	}
+}
+
+func TestCompileSelectorError(t *testing.T) {
+	for _, sel := range []string{"", "p >", "div..a", "[href", "p:nth-child(x)", "p::before", ":hover", "a,", ":not(p"} {
+		if _, err := CompileSelector(sel); err == nil {
+			t.Errorf("%q: want error", sel)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package exhtml
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html/charset"
+)
+
+// maxSitemapSize is the uncompressed size limit of the sitemap protocol.
+const maxSitemapSize = 50 << 20
+
+// maxSitemapDepth bounds how deep ExtractSitemapURLs follows nested
+// sitemap indexes.
+const maxSitemapDepth = 3
+
+// Sitemap is a parsed <urlset> or <sitemapindex>.
+type Sitemap struct {
+	URLs     []SitemapURL
+	Sitemaps []SitemapRef
+}
+
+// SitemapURL is a <url> of a urlset. LastMod is zero if absent.
+type SitemapURL struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq string
+	Priority   float64
+	// News is the news: extension of Google News sitemaps.
+	News *SitemapNews
+	// Images are the image: extensions.
+	Images []SitemapImage
+}
+
+// SitemapNews is a <news:news> entry.
+type SitemapNews struct {
+	PublicationName     string
+	PublicationLanguage string
+	PublicationDate     time.Time
+	Title               string
+	Keywords            []string
+}
+
+// SitemapImage is an <image:image> entry.
+type SitemapImage struct {
+	Loc     string
+	Caption string
+	Title   string
+}
+
+// SitemapRef is a <sitemap> of a sitemapindex.
+type SitemapRef struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type xmlSitemap struct {
+	XMLName  xml.Name
+	URLs     []xmlSitemapURL `xml:"url"`
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+}
+
+type xmlSitemapURL struct {
+	Loc        string  `xml:"loc"`
+	LastMod    string  `xml:"lastmod"`
+	ChangeFreq string  `xml:"changefreq"`
+	Priority   float64 `xml:"priority"`
+	News       *struct {
+		Publication struct {
+			Name     string `xml:"name"`
+			Language string `xml:"language"`
+		} `xml:"publication"`
+		PublicationDate string `xml:"publication_date"`
+		Title           string `xml:"title"`
+		Keywords        string `xml:"keywords"`
+	} `xml:"news"`
+	Images []struct {
+		Loc     string `xml:"loc"`
+		Caption string `xml:"caption"`
+		Title   string `xml:"title"`
+	} `xml:"image"`
+}
+
+// errSitemapTooLarge is returned when a sitemap exceeds its size limit
+// once uncompressed.
+var errSitemapTooLarge = errors.New("sitemap too large")
+
+// sizeLimitReader reads up to n bytes from r and fails with
+// errSitemapTooLarge if r has more.
+type sizeLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var b [1]byte
+		if n, _ := io.ReadFull(l.r, b[:]); n > 0 {
+			return 0, errSitemapTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// ParseSitemap parses a urlset or sitemapindex, gzip compressed or not,
+// of up to 50 MB uncompressed.
+func ParseSitemap(raw []byte) (*Sitemap, error) {
+	return parseSitemap(raw, maxSitemapSize)
+}
+
+// parseSitemap is ParseSitemap with an uncompressed size limit, <= 0 means
+// no limit.
+func parseSitemap(raw []byte, limit int64) (*Sitemap, error) {
+	var r io.Reader = bytes.NewReader(raw)
+	if len(raw) > 1 && raw[0] == 0x1f && raw[1] == 0x8b {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	if limit > 0 {
+		r = &sizeLimitReader{r: r, n: limit}
+	}
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charset.NewReaderLabel
+	var xs xmlSitemap
+	if err := d.Decode(&xs); err != nil {
+		return nil, err
+	}
+	if xs.XMLName.Local != "urlset" && xs.XMLName.Local != "sitemapindex" {
+		return nil, errors.Errorf("unexpected root element <%s>", xs.XMLName.Local)
+	}
+
+	sm := &Sitemap{}
+	for _, u := range xs.URLs {
+		su := SitemapURL{
+			Loc:        strings.TrimSpace(u.Loc),
+			LastMod:    parseW3CTime(u.LastMod),
+			ChangeFreq: strings.TrimSpace(u.ChangeFreq),
+			Priority:   u.Priority,
+		}
+		if n := u.News; n != nil {
+			su.News = &SitemapNews{
+				PublicationName:     strings.TrimSpace(n.Publication.Name),
+				PublicationLanguage: strings.TrimSpace(n.Publication.Language),
+				PublicationDate:     parseW3CTime(n.PublicationDate),
+				Title:               strings.TrimSpace(n.Title),
+			}
+			for _, k := range strings.Split(n.Keywords, ",") {
+				if k = strings.TrimSpace(k); k != "" {
+					su.News.Keywords = append(su.News.Keywords, k)
+				}
+			}
+		}
+		for _, img := range u.Images {
+			su.Images = append(su.Images, SitemapImage{
+				Loc:     strings.TrimSpace(img.Loc),
+				Caption: strings.TrimSpace(img.Caption),
+				Title:   strings.TrimSpace(img.Title),
+			})
+		}
+		sm.URLs = append(sm.URLs, su)
+	}
+	for _, s := range xs.Sitemaps {
+		sm.Sitemaps = append(sm.Sitemaps, SitemapRef{
+			Loc:     strings.TrimSpace(s.Loc),
+			LastMod: parseW3CTime(s.LastMod),
+		})
+	}
+	return sm, nil
+}
+
+var w3cLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// parseW3CTime parses the W3C datetime profile of ISO 8601 used by
+// sitemaps, returning the zero time if s is empty or malformed.
+func parseW3CTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, l := range w3cLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ExtractSitemap fetches and parses the sitemap at weburl. Sitemaps may be
+// 50 MB uncompressed, a limit set with WithMaxBodySize applies instead.
+func (f *Fetcher) ExtractSitemap(weburl string) (*Sitemap, error) {
+	return f.ExtractSitemapContext(context.Background(), weburl)
+}
+
+// ExtractSitemapContext is like ExtractSitemap with a cancelable ctx.
+func (f *Fetcher) ExtractSitemapContext(ctx context.Context, weburl string) (*Sitemap, error) {
+	limit := f.sitemapSizeLimit()
+	raw, _, err := f.getLimit(ctx, weburl, limit)
+	if err != nil {
+		return nil, err
+	}
+	sm, err := parseSitemap(raw, limit)
+	if errors.Is(err, errSitemapTooLarge) {
+		return nil, &TooLargeError{URL: weburl, Limit: limit}
+	}
+	if err != nil {
+		return nil, &ParseError{URL: weburl, Err: err}
+	}
+	return sm, nil
+}
+
+// sitemapSizeLimit returns the size limit of the sitemaps fetched by f,
+// the 50 MB of the sitemap protocol unless WithMaxBodySize was given.
+func (f *Fetcher) sitemapSizeLimit() int64 {
+	if f.maxBodySizeSet {
+		return f.maxBodySize
+	}
+	return maxSitemapSize
+}
+
+// ExtractSitemapURLs fetches the sitemap at weburl and returns its URL
+// entries, following sitemap indexes.
+func (f *Fetcher) ExtractSitemapURLs(weburl string) ([]SitemapURL, error) {
+	return f.ExtractSitemapURLsContext(context.Background(), weburl)
+}
+
+// ExtractSitemapURLsContext is like ExtractSitemapURLs with a cancelable ctx.
+func (f *Fetcher) ExtractSitemapURLsContext(ctx context.Context, weburl string) ([]SitemapURL, error) {
+	var urls []SitemapURL
+	seen := map[string]bool{}
+	var walk func(src string, depth int) error
+	walk = func(src string, depth int) error {
+		if seen[src] || depth > maxSitemapDepth {
+			return nil
+		}
+		seen[src] = true
+		sm, err := f.ExtractSitemapContext(ctx, src)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, sm.URLs...)
+		for _, s := range sm.Sitemaps {
+			if err := walk(s.Loc, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(weburl, 0); err != nil {
+		return urls, err
+	}
+	return urls, nil
+}
+
+// SitemapsFromRobots returns the Sitemap lines of the robots.txt of
+// weburl's host.
+func (f *Fetcher) SitemapsFromRobots(weburl string) ([]string, error) {
+	return f.SitemapsFromRobotsContext(context.Background(), weburl)
+}
+
+// SitemapsFromRobotsContext is like SitemapsFromRobots with a cancelable ctx.
+func (f *Fetcher) SitemapsFromRobotsContext(ctx context.Context, weburl string) ([]string, error) {
+	u, err := url.Parse(weburl)
+	if err != nil {
+		return nil, err
+	}
+	c := f.robots
+	if c == nil {
+		c = NewRobotsChecker(f, "")
+	}
+	rb, err := c.Robots(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return rb.Sitemaps, nil
+}
+
+// ExtractSitemap fetches and parses the sitemap at weburl. Sitemaps may be
+// 50 MB uncompressed, a limit set with WithMaxBodySize applies instead.
+func ExtractSitemap(weburl string) (*Sitemap, error) {
+	return defaultFetcher.ExtractSitemap(weburl)
+}
+
+// ExtractSitemapContext is like ExtractSitemap with a cancelable ctx.
+func ExtractSitemapContext(ctx context.Context, weburl string) (*Sitemap, error) {
+	return defaultFetcher.ExtractSitemapContext(ctx, weburl)
+}
+
+// ExtractSitemapURLs fetches the sitemap at weburl and returns its URL
+// entries, following sitemap indexes.
+func ExtractSitemapURLs(weburl string) ([]SitemapURL, error) {
+	return defaultFetcher.ExtractSitemapURLs(weburl)
+}
+
+// ExtractSitemapURLsContext is like ExtractSitemapURLs with a cancelable ctx.
+func ExtractSitemapURLsContext(ctx context.Context, weburl string) ([]SitemapURL, error) {
+	return defaultFetcher.ExtractSitemapURLsContext(ctx, weburl)
+}
+
+// SitemapsFromRobots returns the Sitemap lines of the robots.txt of
+// weburl's host.
+func SitemapsFromRobots(weburl string) ([]string, error) {
+	return defaultFetcher.SitemapsFromRobots(weburl)
+}
+
+// SitemapsFromRobotsContext is like SitemapsFromRobots with a cancelable ctx.
+func SitemapsFromRobotsContext(ctx context.Context, weburl string) ([]string, error) {
+	return defaultFetcher.SitemapsFromRobotsContext(ctx, weburl)
+}
@@ -0,0 +1,146 @@
+package exhtml
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSitemap = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+        xmlns:news="http://www.google.com/schemas/sitemap-news/0.9"
+        xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+<url>
+  <loc>https://www.cna.com.tw/news/aopl/202009290075.aspx</loc>
+  <lastmod>2020-09-29T11:49:00+08:00</lastmod>
+  <changefreq>hourly</changefreq>
+  <priority>0.8</priority>
+  <news:news>
+    <news:publication>
+      <news:name>中央社 CNA</news:name>
+      <news:language>zh-tw</news:language>
+    </news:publication>
+    <news:publication_date>2020-09-29T11:27:00+08:00</news:publication_date>
+    <news:title>美國總統大選辯論</news:title>
+    <news:keywords>美國, 大選</news:keywords>
+  </news:news>
+  <image:image>
+    <image:loc>https://imgcdn.cna.com.tw/news/20200929.jpg</image:loc>
+    <image:caption>辯論會場</image:caption>
+  </image:image>
+</url>
+<url>
+  <loc>https://www.cna.com.tw/news/aopl/202009290076.aspx</loc>
+  <lastmod>2020-09-29</lastmod>
+</url>
+</urlset>`
+
+func TestParseSitemap(t *testing.T) {
+	sm, err := ParseSitemap([]byte(testSitemap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sm.URLs) != 2 || len(sm.Sitemaps) != 0 {
+		t.Fatalf("want 2 urls, got: %+v", sm)
+	}
+	u := sm.URLs[0]
+	if u.Loc != "https://www.cna.com.tw/news/aopl/202009290075.aspx" || u.ChangeFreq != "hourly" || u.Priority != 0.8 {
+		t.Errorf("unexpected url: %+v", u)
+	}
+	if want := time.Date(2020, 9, 29, 3, 49, 0, 0, time.UTC); !u.LastMod.Equal(want) {
+		t.Errorf("want lastmod %v, got: %v", want, u.LastMod)
+	}
+	if u.News == nil || u.News.PublicationName != "中央社 CNA" || u.News.PublicationLanguage != "zh-tw" ||
+		u.News.Title != "美國總統大選辯論" || len(u.News.Keywords) != 2 || u.News.PublicationDate.IsZero() {
+		t.Errorf("unexpected news: %+v", u.News)
+	}
+	if len(u.Images) != 1 || u.Images[0].Caption != "辯論會場" {
+		t.Errorf("unexpected images: %+v", u.Images)
+	}
+	if want := time.Date(2020, 9, 29, 0, 0, 0, 0, time.UTC); !sm.URLs[1].LastMod.Equal(want) || sm.URLs[1].News != nil {
+		t.Errorf("unexpected second url: %+v", sm.URLs[1])
+	}
+	if _, err := ParseSitemap([]byte(testRss)); err == nil {
+		t.Errorf("want error for an rss document")
+	}
+}
+
+func TestExtractSitemapURLs(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(testSitemap))
+	zw.Close()
+
+	var ts *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "User-agent: *\nDisallow:\nSitemap: %s/sitemap_index.xml\n", ts.URL)
+	})
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>%s/news.xml.gz</loc><lastmod>2020-09-29</lastmod></sitemap>
+<sitemap><loc>%s/sitemap_index.xml</loc></sitemap>
+</sitemapindex>`, ts.URL, ts.URL)
+	})
+	mux.HandleFunc("/news.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-gzip")
+		w.Write(gz.Bytes())
+	})
+	ts = httptest.NewServer(mux)
+	defer ts.Close()
+
+	sitemaps, err := SitemapsFromRobots(ts.URL + "/news/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sitemaps) != 1 {
+		t.Fatalf("want 1 sitemap in robots.txt, got: %v", sitemaps)
+	}
+	urls, err := ExtractSitemapURLs(sitemaps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 2 || urls[0].News == nil {
+		t.Errorf("want 2 urls from the gzipped sitemap, got: %+v", urls)
+	}
+}
+
+func TestExtractSitemapSizeLimit(t *testing.T) {
+	// past DefaultMaxBodySize, within the 50 MB of the sitemap protocol
+	large := strings.Replace(testSitemap, "<url>", "<!-- "+strings.Repeat("x", 11<<20)+" --><url>", 1)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(testSitemap))
+	zw.Close()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/large.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, large)
+	})
+	mux.HandleFunc("/sitemap.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(gz.Bytes())
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	sm, err := ExtractSitemap(ts.URL + "/large.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sm.URLs) != 2 {
+		t.Errorf("want 2 urls, got: %d", len(sm.URLs))
+	}
+
+	// an explicit limit applies to the uncompressed sitemap
+	f := NewFetcher(WithMaxBodySize(int64(gz.Len())))
+	_, err = f.ExtractSitemap(ts.URL + "/sitemap.xml.gz")
+	var tl *TooLargeError
+	if !errors.As(err, &tl) || tl.Limit != int64(gz.Len()) {
+		t.Errorf("want *TooLargeError, got: %v", err)
+	}
+}
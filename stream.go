@@ -0,0 +1,260 @@
+package exhtml
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// voidElements have no end tag.
+var voidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"keygen": true,
+	"link":   true,
+	"meta":   true,
+	"param":  true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
+
+// pClosers are the start tags that close an open <p>.
+var pClosers = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"center": true, "dd": true, "details": true, "dialog": true, "dir": true,
+	"div": true, "dl": true, "dt": true, "fieldset": true, "figcaption": true,
+	"figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hgroup": true, "hr": true, "li": true, "listing": true, "main": true,
+	"menu": true, "nav": true, "ol": true, "p": true, "plaintext": true,
+	"pre": true, "search": true, "section": true, "summary": true,
+	"table": true, "ul": true, "xmp": true,
+}
+
+// buttonScope are the elements an open <p> is looked for up to, the
+// button scope of the HTML parser.
+var buttonScope = []string{
+	"applet", "button", "caption", "html", "marquee", "object", "table",
+	"td", "th", "template",
+}
+
+// listItemScope are the special elements of the HTML parser but address,
+// div and p, an open <li>, <dt> or <dd> is looked for up to.
+var listItemScope = []string{
+	"applet", "article", "aside", "blockquote", "body", "button", "caption",
+	"center", "colgroup", "dd", "details", "dialog", "dir", "dl", "dt",
+	"fieldset", "figcaption", "figure", "footer", "form", "frameset", "h1",
+	"h2", "h3", "h4", "h5", "h6", "head", "header", "hgroup", "html",
+	"iframe", "li", "listing", "main", "marquee", "menu", "nav", "noembed",
+	"noframes", "noscript", "object", "ol", "plaintext", "pre", "script",
+	"search", "section", "select", "style", "summary", "table", "tbody",
+	"td", "template", "textarea", "tfoot", "th", "thead", "title", "tr",
+	"ul", "xmp",
+}
+
+// impliedEnd are the start tags that close an open element of closes,
+// unless one of boundaries is open in between.
+var impliedEnd = map[string]struct{ closes, boundaries []string }{
+	"li":       {[]string{"li"}, listItemScope},
+	"dt":       {[]string{"dt", "dd"}, listItemScope},
+	"dd":       {[]string{"dt", "dd"}, listItemScope},
+	"tr":       {[]string{"tr"}, []string{"table", "thead", "tbody", "tfoot"}},
+	"td":       {[]string{"td", "th"}, []string{"tr", "table"}},
+	"th":       {[]string{"td", "th"}, []string{"tr", "table"}},
+	"thead":    {[]string{"thead", "tbody", "tfoot"}, []string{"table"}},
+	"tbody":    {[]string{"thead", "tbody", "tfoot"}, []string{"table"}},
+	"tfoot":    {[]string{"thead", "tbody", "tfoot"}, []string{"table"}},
+	"option":   {[]string{"option"}, []string{"select", "datalist", "optgroup"}},
+	"optgroup": {[]string{"option", "optgroup"}, []string{"select"}},
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// implicitClose returns the length of the stack of open elements once the
+// start tag name closed the elements it implies.
+func implicitClose(stack []string, name string) int {
+	length := len(stack)
+	if rule, ok := impliedEnd[name]; ok {
+		if i := openIndex(stack, rule.closes, rule.boundaries); i >= 0 {
+			length = i
+		}
+	}
+	if pClosers[name] {
+		if i := openIndex(stack[:length], []string{"p"}, buttonScope); i >= 0 {
+			length = i
+		}
+	}
+	return length
+}
+
+// openIndex returns the index in stack of the innermost element of names
+// not below one of boundaries, or -1.
+func openIndex(stack, names, boundaries []string) int {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if containsString(names, stack[i]) {
+			return i
+		}
+		if containsString(boundaries, stack[i]) {
+			break
+		}
+	}
+	return -1
+}
+
+type streamCapture struct {
+	// depth is the index of the element in the stack of open elements.
+	depth int
+	buf   bytes.Buffer
+	done  bool
+}
+
+// StreamElements tokenizes the HTML read from r without building a tree
+// and calls fn with the exact bytes of each element matched by m, from its
+// start tag to its balanced end tag, in the order of their start tags.
+// Void elements and elements closed implicitly, such as <li> by the next
+// <li>, end where the HTML parser would end them; elements still open at
+// the end of r are passed as they are.
+//
+// m is given the start tag only: a node without parent, children or text,
+// so Tag, Attr, AttrRegex, Class and ID apply but HasChild or Text do not.
+// StreamElements stops and returns the first error of fn.
+func StreamElements(r io.Reader, m Matcher, fn func(raw []byte) error) error {
+	z := html.NewTokenizer(r)
+	var stack []string
+	var captures []*streamCapture
+	// end marks done the captures of the elements above length and
+	// passes the done ones leading captures to fn.
+	end := func(length int) error {
+		stack = stack[:length]
+		for _, c := range captures {
+			if !c.done && c.depth >= length {
+				c.done = true
+			}
+		}
+		for len(captures) > 0 && captures[0].done {
+			if err := fn(captures[0].buf.Bytes()); err != nil {
+				return err
+			}
+			captures = captures[1:]
+		}
+		return nil
+	}
+	write := func(raw []byte) {
+		for _, c := range captures {
+			if !c.done {
+				c.buf.Write(raw)
+			}
+		}
+	}
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return z.Err()
+			}
+			for _, c := range captures {
+				c.done = true
+			}
+			return end(0)
+		}
+		raw := z.Raw()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			// copy raw before Token reuses its buffer
+			raw = append([]byte(nil), raw...)
+			t := z.Token()
+			if err := end(implicitClose(stack, t.Data)); err != nil {
+				return err
+			}
+			write(raw)
+			empty := tt == html.SelfClosingTagToken || voidElements[t.Data]
+			if m(&html.Node{Type: html.ElementNode, Data: t.Data, DataAtom: t.DataAtom, Attr: t.Attr}) {
+				c := &streamCapture{depth: len(stack), done: empty}
+				c.buf.Write(raw)
+				captures = append(captures, c)
+			}
+			if !empty {
+				stack = append(stack, t.Data)
+			}
+			if err := end(len(stack)); err != nil {
+				return err
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			i := len(stack) - 1
+			for i >= 0 && stack[i] != string(name) {
+				i--
+			}
+			if i < 0 {
+				// stray end tag
+				write(raw)
+				break
+			}
+			// the elements left open inside end before the end tag
+			if err := end(i + 1); err != nil {
+				return err
+			}
+			write(raw)
+			if err := end(i); err != nil {
+				return err
+			}
+		default:
+			write(raw)
+		}
+	}
+}
+
+// ExtractElements returns the exact bytes of the elements of raw matched
+// by m, see StreamElements.
+func ExtractElements(raw []byte, m Matcher) ([][]byte, error) {
+	var elems [][]byte
+	err := StreamElements(bytes.NewReader(raw), m, func(elem []byte) error {
+		elems = append(elems, elem)
+		return nil
+	})
+	return elems, err
+}
+
+// errStopStream stops StreamElements after the first element.
+var errStopStream = errors.New("exhtml: stop stream")
+
+// extractFirstElement returns the bytes of the first element of raw
+// matched by m, or nil.
+func extractFirstElement(raw []byte, m Matcher) []byte {
+	var first []byte
+	StreamElements(bytes.NewReader(raw), m, func(elem []byte) error {
+		first = elem
+		return errStopStream
+	})
+	return first
+}
+
+// joinElements returns the bytes of the elements of raw matched by m one
+// after another, or nil if raw can not be read.
+func joinElements(raw []byte, m Matcher) []byte {
+	var b bytes.Buffer
+	err := StreamElements(bytes.NewReader(raw), m, func(elem []byte) error {
+		b.Write(elem)
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	return b.Bytes()
+}
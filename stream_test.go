@@ -0,0 +1,149 @@
+package exhtml
+
+import (
+	"strings"
+	"testing"
+)
+
+func extractStrings(t *testing.T, raw string, m Matcher) []string {
+	t.Helper()
+	elems, err := ExtractElements([]byte(raw), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range elems {
+		got = append(got, string(e))
+	}
+	return got
+}
+
+func TestExtractElements(t *testing.T) {
+	tcs := []struct {
+		name string
+		raw  string
+		m    Matcher
+		want []string
+	}{
+		{
+			"nested",
+			`<div class="a">x<div class="a">y</div>z</div><div class="a">w</div>`,
+			Class("a"),
+			[]string{`<div class="a">x<div class="a">y</div>z</div>`, `<div class="a">y</div>`, `<div class="a">w</div>`},
+		},
+		{
+			"void",
+			`<p>a<img src="x.png">b<br/>c</p>`,
+			Tag("img", "br", "p"),
+			[]string{`<p>a<img src="x.png">b<br/>c</p>`, `<img src="x.png">`, `<br/>`},
+		},
+		{
+			"implicit li",
+			`<ul><li>one<li>two</ul><p>after`,
+			Tag("li"),
+			[]string{`<li>one`, `<li>two`},
+		},
+		{
+			"implicit p",
+			`<p>one<p>two<div>three</div>`,
+			Tag("p"),
+			[]string{`<p>one`, `<p>two`},
+		},
+		{
+			"implicit p in button scope",
+			`<p>q<span>z<div>w</div>`,
+			Tag("p", "div"),
+			[]string{`<p>q<span>z`, `<div>w</div>`},
+		},
+		{
+			"p outside button scope",
+			`<p>x<button><div>in</div></button>y</p>`,
+			Tag("p"),
+			[]string{`<p>x<button><div>in</div></button>y</p>`},
+		},
+		{
+			"p closed by li",
+			`<div><p>one<li>two</div>`,
+			Tag("p"),
+			[]string{`<p>one`},
+		},
+		{
+			"p closed by center and summary",
+			`<p>a<center>b</center><p>c<summary>d</summary>`,
+			Tag("p"),
+			[]string{`<p>a`, `<p>c`},
+		},
+		{
+			"li in a special element",
+			`<li>a<section><li>b</section>`,
+			Tag("li"),
+			[]string{`<li>a<section><li>b</section>`, `<li>b`},
+		},
+		{
+			"li closing li and p",
+			`<ul><li>a<p>b<li>c</ul>`,
+			Tag("li", "p"),
+			[]string{`<li>a<p>b`, `<p>b`, `<li>c`},
+		},
+		{
+			"dd through div",
+			`<dl><dt>a<div>b<dd>c</div></dl>`,
+			Tag("dt", "dd"),
+			[]string{`<dt>a<div>b`, `<dd>c</div>`},
+		},
+		{
+			"script raw text",
+			`<div id="x"><script>if (a < b) { s = "</div>" }</script></div>`,
+			ID("x"),
+			[]string{`<div id="x"><script>if (a < b) { s = "</div>" }</script></div>`},
+		},
+		{
+			"stray end tag",
+			`<div id="x"></span>a</div>`,
+			ID("x"),
+			[]string{`<div id="x"></span>a</div>`},
+		},
+		{
+			"unclosed",
+			`<div id="x"><b>a`,
+			ID("x"),
+			[]string{`<div id="x"><b>a`},
+		},
+	}
+	for _, tc := range tcs {
+		got := extractStrings(t, tc.raw, tc.m)
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStreamElementsStop(t *testing.T) {
+	n := 0
+	err := StreamElements(strings.NewReader(`<p>1</p><p>2</p>`), Tag("p"), func(raw []byte) error {
+		n++
+		return errStopStream
+	})
+	if err != errStopStream || n != 1 {
+		t.Errorf("got %v after %d elements", err, n)
+	}
+}
+
+func TestRawExtractors(t *testing.T) {
+	raw := []byte(`<div id="a" class="wsw"><p>1</p><div>in</div><p>2</p></div><div class="wsw"><p>3</div>`)
+	if got, want := string(DivWithAttr2(raw, "class", "wsw")), `<div id="a" class="wsw"><p>1</p><div>in</div><p>2</p></div>`; got != want {
+		t.Errorf("DivWithAttr2: got %q, want %q", got, want)
+	}
+	if got, want := string(ElementsByTagAndId2(raw, "div", "a")), `<div id="a" class="wsw"><p>1</p><div>in</div><p>2</p></div>`; got != want {
+		t.Errorf("ElementsByTagAndId2: got %q, want %q", got, want)
+	}
+	if got, want := string(ElementsByTagAndClass2(raw, "div", "wsw")), string(raw); got != want {
+		t.Errorf("ElementsByTagAndClass2: got %q, want %q", got, want)
+	}
+	if got, want := string(ElementsByTag2(raw, "p")), `<p>1</p><p>2</p><p>3`; got != want {
+		t.Errorf("ElementsByTag2: got %q, want %q", got, want)
+	}
+	if got := ElementsByTagAndId2(raw, "div", "b"); got != nil {
+		t.Errorf("ElementsByTagAndId2: got %q, want nil", got)
+	}
+}
@@ -0,0 +1,931 @@
+package exhtml
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// XPath is a compiled XPath 1.0 expression, such as
+// "//div[@class='list']/ul/li[position() <= 3]/a/@href".
+//
+// It supports absolute and relative location paths with the child,
+// descendant, descendant-or-self, self, parent, ancestor, ancestor-or-self,
+// following-sibling, preceding-sibling and attribute axes and their
+// abbreviations, the node tests name, *, text(), node() and comment(),
+// predicates, unions, the or, and, =, !=, <, <=, >, >=, + and - operators,
+// and the functions last, position, count, not, true, false, string,
+// number, boolean, concat, contains, starts-with, normalize-space,
+// string-length, name and local-name. Names are matched ignoring case.
+type XPath struct {
+	src  string
+	expr xpathExpr
+}
+
+// CompileXPath parses an XPath expression.
+func CompileXPath(expr string) (*XPath, error) {
+	toks, err := xpathTokenize(expr)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "exhtml: CompileXPath %q", expr)
+	}
+	p := &xpathParser{toks: toks}
+	e, err := p.parseExpr()
+	if err == nil && !p.eof() {
+		err = errors.Errorf("unexpected %q", p.peek().val)
+	}
+	if err != nil {
+		return nil, errors.WithMessagef(err, "exhtml: CompileXPath %q", expr)
+	}
+	return &XPath{src: expr, expr: e}, nil
+}
+
+// MustCompileXPath is like CompileXPath but panics if expr cannot be
+// parsed.
+func MustCompileXPath(expr string) *XPath {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// String returns the source of x.
+func (x *XPath) String() string {
+	return x.src
+}
+
+// Nodes evaluates x with n as context node and returns the resulting
+// nodes in document order. Attributes and values other than node sets
+// give no nodes.
+func (x *XPath) Nodes(n *html.Node) []*html.Node {
+	ns, ok := x.expr.eval(&xpathContext{item: xpathItem{n, -1}, pos: 1, size: 1}).(xpathNodeSet)
+	if !ok {
+		return nil
+	}
+	var nodes []*html.Node
+	for _, it := range ns {
+		if it.attr < 0 {
+			nodes = append(nodes, it.n)
+		}
+	}
+	return nodes
+}
+
+// Strings evaluates x with n as context node and returns the string
+// values of the resulting nodes and attributes, or the string of any other
+// value.
+func (x *XPath) Strings(n *html.Node) []string {
+	v := x.expr.eval(&xpathContext{item: xpathItem{n, -1}, pos: 1, size: 1})
+	ns, ok := v.(xpathNodeSet)
+	if !ok {
+		return []string{xpathString(v)}
+	}
+	var ss []string
+	for _, it := range ns {
+		ss = append(ss, it.String())
+	}
+	return ss
+}
+
+// XPathNodes evaluates expr with n as context node and returns the
+// resulting nodes, see XPath.Nodes.
+func XPathNodes(n *html.Node, expr string) ([]*html.Node, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.Nodes(n), nil
+}
+
+// XPathStrings evaluates expr with n as context node and returns the
+// resulting strings, see XPath.Strings.
+func XPathStrings(n *html.Node, expr string) ([]string, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.Strings(n), nil
+}
+
+// xpathItem is a node, or its attribute at index attr if attr >= 0.
+type xpathItem struct {
+	n    *html.Node
+	attr int
+}
+
+func (it xpathItem) String() string {
+	if it.attr >= 0 {
+		return it.n.Attr[it.attr].Val
+	}
+	switch it.n.Type {
+	case html.TextNode, html.CommentNode:
+		return it.n.Data
+	}
+	return nodeText(it.n)
+}
+
+// The values of XPath: xpathNodeSet, string, float64 and bool.
+type xpathValue interface{}
+
+type xpathNodeSet []xpathItem
+
+type xpathContext struct {
+	item      xpathItem
+	pos, size int
+}
+
+type xpathExpr interface {
+	eval(c *xpathContext) xpathValue
+}
+
+func xpathString(v xpathValue) string {
+	switch v := v.(type) {
+	case xpathNodeSet:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].String()
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+func xpathNumber(v xpathValue) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(xpathString(v)), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func xpathBool(v xpathValue) bool {
+	switch v := v.(type) {
+	case xpathNodeSet:
+		return len(v) > 0
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case bool:
+		return v
+	}
+	return false
+}
+
+type xpathLiteral struct{ v xpathValue }
+
+func (e xpathLiteral) eval(c *xpathContext) xpathValue { return e.v }
+
+type xpathNeg struct{ e xpathExpr }
+
+func (e xpathNeg) eval(c *xpathContext) xpathValue { return -xpathNumber(e.e.eval(c)) }
+
+type xpathBinary struct {
+	op   string
+	l, r xpathExpr
+}
+
+func (e xpathBinary) eval(c *xpathContext) xpathValue {
+	switch e.op {
+	case "or":
+		return xpathBool(e.l.eval(c)) || xpathBool(e.r.eval(c))
+	case "and":
+		return xpathBool(e.l.eval(c)) && xpathBool(e.r.eval(c))
+	case "+":
+		return xpathNumber(e.l.eval(c)) + xpathNumber(e.r.eval(c))
+	case "-":
+		return xpathNumber(e.l.eval(c)) - xpathNumber(e.r.eval(c))
+	case "|":
+		l, _ := e.l.eval(c).(xpathNodeSet)
+		r, _ := e.r.eval(c).(xpathNodeSet)
+		return sortNodeSet(append(append(xpathNodeSet(nil), l...), r...))
+	}
+	return xpathCompare(e.op, e.l.eval(c), e.r.eval(c))
+}
+
+// xpathCompare compares two values the XPath way: node sets compare true
+// if any of their nodes does.
+func xpathCompare(op string, l, r xpathValue) bool {
+	if ns, ok := l.(xpathNodeSet); ok {
+		if _, ok := r.(bool); ok {
+			return xpathCompareAtoms(op, xpathBool(ns), r)
+		}
+		for _, it := range ns {
+			if xpathCompare(op, it.String(), r) {
+				return true
+			}
+		}
+		return false
+	}
+	if ns, ok := r.(xpathNodeSet); ok {
+		if _, ok := l.(bool); ok {
+			return xpathCompareAtoms(op, l, xpathBool(ns))
+		}
+		for _, it := range ns {
+			if xpathCompare(op, l, it.String()) {
+				return true
+			}
+		}
+		return false
+	}
+	return xpathCompareAtoms(op, l, r)
+}
+
+func xpathCompareAtoms(op string, l, r xpathValue) bool {
+	if op == "=" || op == "!=" {
+		var eq bool
+		_, lb := l.(bool)
+		_, rb := r.(bool)
+		_, lf := l.(float64)
+		_, rf := r.(float64)
+		switch {
+		case lb || rb:
+			eq = xpathBool(l) == xpathBool(r)
+		case lf || rf:
+			eq = xpathNumber(l) == xpathNumber(r)
+		default:
+			eq = xpathString(l) == xpathString(r)
+		}
+		return eq == (op == "=")
+	}
+	a, b := xpathNumber(l), xpathNumber(r)
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+type xpathFunc struct {
+	name string
+	args []xpathExpr
+}
+
+// xpathArity are the min and max number of arguments of the functions.
+var xpathArity = map[string][2]int{
+	"last":            {0, 0},
+	"position":        {0, 0},
+	"count":           {1, 1},
+	"not":             {1, 1},
+	"true":            {0, 0},
+	"false":           {0, 0},
+	"string":          {0, 1},
+	"number":          {0, 1},
+	"boolean":         {1, 1},
+	"concat":          {2, math.MaxInt32},
+	"contains":        {2, 2},
+	"starts-with":     {2, 2},
+	"normalize-space": {0, 1},
+	"string-length":   {0, 1},
+	"name":            {0, 1},
+	"local-name":      {0, 1},
+}
+
+func (e xpathFunc) eval(c *xpathContext) xpathValue {
+	arg := func(i int) xpathValue {
+		if i < len(e.args) {
+			return e.args[i].eval(c)
+		}
+		return xpathNodeSet{c.item}
+	}
+	switch e.name {
+	case "last":
+		return float64(c.size)
+	case "position":
+		return float64(c.pos)
+	case "count":
+		ns, _ := arg(0).(xpathNodeSet)
+		return float64(len(ns))
+	case "not":
+		return !xpathBool(arg(0))
+	case "true":
+		return true
+	case "false":
+		return false
+	case "string":
+		return xpathString(arg(0))
+	case "number":
+		return xpathNumber(arg(0))
+	case "boolean":
+		return xpathBool(arg(0))
+	case "concat":
+		var b strings.Builder
+		for i := range e.args {
+			b.WriteString(xpathString(arg(i)))
+		}
+		return b.String()
+	case "contains":
+		return strings.Contains(xpathString(arg(0)), xpathString(arg(1)))
+	case "starts-with":
+		return strings.HasPrefix(xpathString(arg(0)), xpathString(arg(1)))
+	case "normalize-space":
+		return strings.Join(strings.Fields(xpathString(arg(0))), " ")
+	case "string-length":
+		return float64(len([]rune(xpathString(arg(0)))))
+	case "name", "local-name":
+		ns, _ := arg(0).(xpathNodeSet)
+		if len(ns) == 0 {
+			return ""
+		}
+		if it := ns[0]; it.attr >= 0 {
+			return it.n.Attr[it.attr].Key
+		} else if it.n.Type == html.ElementNode {
+			return it.n.Data
+		}
+		return ""
+	}
+	return nil
+}
+
+// xpathPath is a location path, starting at the context node, at the root
+// if absolute, or at the node set of filter.
+type xpathPath struct {
+	filter   xpathExpr
+	absolute bool
+	steps    []*xpathStep
+}
+
+func (e xpathPath) eval(c *xpathContext) xpathValue {
+	var ns xpathNodeSet
+	switch {
+	case e.filter != nil:
+		v, ok := e.filter.eval(c).(xpathNodeSet)
+		if !ok {
+			return xpathNodeSet(nil)
+		}
+		ns = v
+	case e.absolute:
+		root := c.item.n
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		ns = xpathNodeSet{{root, -1}}
+	default:
+		ns = xpathNodeSet{c.item}
+	}
+	for _, s := range e.steps {
+		ns = s.apply(ns)
+	}
+	return ns
+}
+
+// xpathFilter is a primary expression filtered by predicates.
+type xpathFilter struct {
+	primary xpathExpr
+	preds   []xpathExpr
+}
+
+func (e xpathFilter) eval(c *xpathContext) xpathValue {
+	v := e.primary.eval(c)
+	ns, ok := v.(xpathNodeSet)
+	if !ok {
+		return v
+	}
+	for _, p := range e.preds {
+		ns = filterNodeSet(ns, p)
+	}
+	return ns
+}
+
+type xpathStep struct {
+	axis string
+	// test is a name, "*", "text()", "node()" or "comment()".
+	test  string
+	preds []xpathExpr
+}
+
+func (s *xpathStep) apply(in xpathNodeSet) xpathNodeSet {
+	var out xpathNodeSet
+	seen := map[xpathItem]bool{}
+	for _, it := range in {
+		var cands xpathNodeSet
+		for _, c := range xpathAxis(it, s.axis) {
+			if s.matches(c) {
+				cands = append(cands, c)
+			}
+		}
+		for _, p := range s.preds {
+			cands = filterNodeSet(cands, p)
+		}
+		for _, c := range cands {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return sortNodeSet(out)
+}
+
+func (s *xpathStep) matches(it xpathItem) bool {
+	if it.attr >= 0 {
+		if s.axis != "attribute" && s.test != "node()" {
+			return false
+		}
+		return s.test == "*" || s.test == "node()" || strings.EqualFold(it.n.Attr[it.attr].Key, s.test)
+	}
+	switch s.test {
+	case "node()":
+		return true
+	case "text()":
+		return it.n.Type == html.TextNode
+	case "comment()":
+		return it.n.Type == html.CommentNode
+	case "*":
+		return it.n.Type == html.ElementNode
+	}
+	return it.n.Type == html.ElementNode && strings.EqualFold(it.n.Data, s.test)
+}
+
+// xpathAxis returns the items of axis from it in proximity order.
+func xpathAxis(it xpathItem, axis string) xpathNodeSet {
+	var ns xpathNodeSet
+	n := it.n
+	if it.attr >= 0 {
+		switch axis {
+		case "self", "descendant-or-self":
+			return xpathNodeSet{it}
+		case "parent":
+			return xpathNodeSet{{n, -1}}
+		case "ancestor-or-self":
+			ns = append(ns, it)
+			fallthrough
+		case "ancestor":
+			for p := n; p != nil; p = p.Parent {
+				ns = append(ns, xpathItem{p, -1})
+			}
+		}
+		return ns
+	}
+	switch axis {
+	case "self":
+		ns = append(ns, it)
+	case "child":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			ns = append(ns, xpathItem{c, -1})
+		}
+	case "descendant-or-self":
+		ns = append(ns, it)
+		fallthrough
+	case "descendant":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			ForEachNode(c, func(d *html.Node) {
+				ns = append(ns, xpathItem{d, -1})
+			}, nil)
+		}
+	case "parent":
+		if n.Parent != nil {
+			ns = append(ns, xpathItem{n.Parent, -1})
+		}
+	case "ancestor-or-self":
+		ns = append(ns, it)
+		fallthrough
+	case "ancestor":
+		for p := n.Parent; p != nil; p = p.Parent {
+			ns = append(ns, xpathItem{p, -1})
+		}
+	case "following-sibling":
+		for s := n.NextSibling; s != nil; s = s.NextSibling {
+			ns = append(ns, xpathItem{s, -1})
+		}
+	case "preceding-sibling":
+		for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+			ns = append(ns, xpathItem{s, -1})
+		}
+	case "attribute":
+		if n.Type == html.ElementNode {
+			for i := range n.Attr {
+				ns = append(ns, xpathItem{n, i})
+			}
+		}
+	}
+	return ns
+}
+
+// filterNodeSet keeps the items of ns for which pred is true, or whose
+// position it is if it is a number.
+func filterNodeSet(ns xpathNodeSet, pred xpathExpr) xpathNodeSet {
+	var out xpathNodeSet
+	for i, it := range ns {
+		v := pred.eval(&xpathContext{item: it, pos: i + 1, size: len(ns)})
+		if f, ok := v.(float64); ok {
+			if f == float64(i+1) {
+				out = append(out, it)
+			}
+		} else if xpathBool(v) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// sortNodeSet sorts ns in document order, attributes after their element.
+func sortNodeSet(ns xpathNodeSet) xpathNodeSet {
+	if len(ns) < 2 {
+		return ns
+	}
+	root := ns[0].n
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	order := map[*html.Node]int{}
+	ForEachNode(root, func(n *html.Node) {
+		order[n] = len(order)
+	}, nil)
+	sort.SliceStable(ns, func(i, j int) bool {
+		a, b := ns[i], ns[j]
+		if a.n != b.n {
+			return order[a.n] < order[b.n]
+		}
+		return a.attr < b.attr
+	})
+	return ns
+}
+
+type xpathToken struct {
+	kind byte // 'n' name, 's' string, 'f' number, 'o' operator
+	val  string
+}
+
+func xpathTokenize(s string) ([]xpathToken, error) {
+	var toks []xpathToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case strings.IndexByte(" \t\n\r", c) >= 0:
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, errors.Errorf("offset %d: unterminated string", i)
+			}
+			toks = append(toks, xpathToken{'s', s[i+1 : i+1+end]})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			toks = append(toks, xpathToken{'f', s[i:j]})
+			i = j
+		case isXPathNameStart(c):
+			j := i
+			for j < len(s) && (isXPathNameStart(s[j]) || s[j] >= '0' && s[j] <= '9' || s[j] == '-' || s[j] == '.') {
+				j++
+			}
+			toks = append(toks, xpathToken{'n', s[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"//", "::", "..", "!=", "<=", ">=", "/", "(", ")", "[", "]", "@", ",", "|", ".", "=", "<", ">", "+", "-", "*"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, errors.Errorf("offset %d: unexpected %q", i, c)
+			}
+			toks = append(toks, xpathToken{'o', op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+func isXPathNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+var xpathAxes = map[string]bool{
+	"child":              true,
+	"descendant":         true,
+	"descendant-or-self": true,
+	"self":               true,
+	"parent":             true,
+	"ancestor":           true,
+	"ancestor-or-self":   true,
+	"following-sibling":  true,
+	"preceding-sibling":  true,
+	"attribute":          true,
+}
+
+type xpathParser struct {
+	toks []xpathToken
+	pos  int
+}
+
+func (p *xpathParser) eof() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *xpathParser) peek() xpathToken {
+	if p.eof() {
+		return xpathToken{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *xpathParser) peekAt(i int) xpathToken {
+	if p.pos+i >= len(p.toks) {
+		return xpathToken{}
+	}
+	return p.toks[p.pos+i]
+}
+
+// accept consumes the next token if it is the operator op.
+func (p *xpathParser) accept(op string) bool {
+	if t := p.peek(); t.kind == 'o' && t.val == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *xpathParser) expect(op string) error {
+	if !p.accept(op) {
+		if p.eof() {
+			return errors.Errorf("missing %q", op)
+		}
+		return errors.Errorf("unexpected %q, want %q", p.peek().val, op)
+	}
+	return nil
+}
+
+func (p *xpathParser) parseExpr() (xpathExpr, error) {
+	return p.parseBinary(0)
+}
+
+// xpathLevels are the binary operators by increasing precedence.
+var xpathLevels = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+}
+
+func (p *xpathParser) parseBinary(level int) (xpathExpr, error) {
+	if level == len(xpathLevels) {
+		return p.parseUnary()
+	}
+	l, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		op := ""
+		for _, o := range xpathLevels[level] {
+			// or and and are names, the others operators
+			if t.val == o && (t.kind == 'o' || t.kind == 'n' && (o == "or" || o == "and")) {
+				op = o
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = xpathBinary{op: op, l: l, r: r}
+	}
+}
+
+func (p *xpathParser) parseUnary() (xpathExpr, error) {
+	if p.accept("-") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return xpathNeg{e}, nil
+	}
+	l, err := p.parsePathExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		r, err := p.parsePathExpr()
+		if err != nil {
+			return nil, err
+		}
+		l = xpathBinary{op: "|", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *xpathParser) parsePathExpr() (xpathExpr, error) {
+	t := p.peek()
+	isPrimary := t.kind == 's' || t.kind == 'f' || t.kind == 'o' && t.val == "(" ||
+		t.kind == 'n' && p.peekAt(1).val == "(" && p.peekAt(1).kind == 'o' &&
+			t.val != "text" && t.val != "node" && t.val != "comment"
+	if !isPrimary {
+		return p.parseLocationPath()
+	}
+	primary, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	f := xpathFilter{primary: primary}
+	for p.peek().kind == 'o' && p.peek().val == "[" {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return nil, err
+		}
+		f.preds = append(f.preds, pred)
+	}
+	if t := p.peek(); t.kind != 'o' || (t.val != "/" && t.val != "//") {
+		return f, nil
+	}
+	path := xpathPath{filter: f}
+	if err := p.parseSteps(&path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func (p *xpathParser) parsePrimary() (xpathExpr, error) {
+	t := p.peek()
+	p.pos++
+	switch t.kind {
+	case 's':
+		return xpathLiteral{t.val}, nil
+	case 'f':
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, errors.Errorf("bad number %q", t.val)
+		}
+		return xpathLiteral{f}, nil
+	case 'o': // (
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	arity, ok := xpathArity[t.val]
+	if !ok {
+		return nil, errors.Errorf("unsupported function %s()", t.val)
+	}
+	p.pos++ // (
+	fn := xpathFunc{name: t.val}
+	if !p.accept(")") {
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			fn.args = append(fn.args, e)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(fn.args) < arity[0] || len(fn.args) > arity[1] {
+		return nil, errors.Errorf("wrong number of arguments to %s()", t.val)
+	}
+	return fn, nil
+}
+
+func (p *xpathParser) parseLocationPath() (xpathExpr, error) {
+	var path xpathPath
+	switch t := p.peek(); {
+	case t.kind == 'o' && t.val == "/":
+		p.pos++
+		path.absolute = true
+		// "/" alone selects the root
+		if !p.stepStart() {
+			return path, nil
+		}
+	case t.kind == 'o' && t.val == "//":
+		path.absolute = true
+		return path, p.parseSteps(&path)
+	}
+	step, err := p.parseStep()
+	if err != nil {
+		return nil, err
+	}
+	path.steps = append(path.steps, step)
+	return path, p.parseSteps(&path)
+}
+
+// stepStart reports whether a step starts at the current token.
+func (p *xpathParser) stepStart() bool {
+	t := p.peek()
+	return t.kind == 'n' || t.kind == 'o' && (t.val == "." || t.val == ".." || t.val == "@" || t.val == "*")
+}
+
+// parseSteps parses the steps following / or //.
+func (p *xpathParser) parseSteps(path *xpathPath) error {
+	for {
+		t := p.peek()
+		if t.kind != 'o' || (t.val != "/" && t.val != "//") {
+			return nil
+		}
+		p.pos++
+		if t.val == "//" {
+			path.steps = append(path.steps, &xpathStep{axis: "descendant-or-self", test: "node()"})
+		}
+		step, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, step)
+	}
+}
+
+func (p *xpathParser) parseStep() (*xpathStep, error) {
+	if p.accept(".") {
+		return &xpathStep{axis: "self", test: "node()"}, nil
+	}
+	if p.accept("..") {
+		return &xpathStep{axis: "parent", test: "node()"}, nil
+	}
+	step := &xpathStep{axis: "child"}
+	if p.accept("@") {
+		step.axis = "attribute"
+	} else if t := p.peek(); t.kind == 'n' && p.peekAt(1).kind == 'o' && p.peekAt(1).val == "::" {
+		if !xpathAxes[t.val] {
+			return nil, errors.Errorf("unsupported axis %s", t.val)
+		}
+		step.axis = t.val
+		p.pos += 2
+	}
+	t := p.peek()
+	switch {
+	case t.kind == 'o' && t.val == "*":
+		step.test = "*"
+		p.pos++
+	case t.kind == 'n' && (t.val == "text" || t.val == "node" || t.val == "comment") &&
+		p.peekAt(1).kind == 'o' && p.peekAt(1).val == "(":
+		p.pos += 2
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		step.test = t.val + "()"
+	case t.kind == 'n':
+		step.test = t.val
+		p.pos++
+	case p.eof():
+		return nil, errors.New("missing step")
+	default:
+		return nil, errors.Errorf("unexpected %q", t.val)
+	}
+	for p.peek().kind == 'o' && p.peek().val == "[" {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return nil, err
+		}
+		step.preds = append(step.preds, pred)
+	}
+	return step, nil
+}
+
+func (p *xpathParser) parsePredicate() (xpathExpr, error) {
+	p.pos++ // [
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return e, p.expect("]")
+}
@@ -0,0 +1,87 @@
+package exhtml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+var testXPathDoc = `<html><body>
+<div class="list" id="news">
+  <ul>
+    <li><a href="/news/1" title="first">One</a></li>
+    <li class="ad"><a href="/ad">Ad</a></li>
+    <li><a href="/news/2">Two</a> <span>new</span></li>
+    <li><a href="/news/3">  Three  </a></li>
+  </ul>
+  <p>Total: <b>3</b></p>
+</div>
+<div class="footer"><a href="/about">About</a></div>
+</body></html>`
+
+func TestXPath(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testXPathDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		expr string
+		want []string
+	}{
+		{"//div[@class='list']/ul/li/a/@href", []string{"/news/1", "/ad", "/news/2", "/news/3"}},
+		{"//li[not(@class='ad')]/a/text()", []string{"One", "Two", "  Three  "}},
+		{"//li[position() <= 2]/a/@href", []string{"/news/1", "/ad"}},
+		{"//li[last()]/a/@href", []string{"/news/3"}},
+		{"//li[2]/following-sibling::li/a/@href", []string{"/news/2", "/news/3"}},
+		{"//li[3]/preceding-sibling::li[1]/a/@href", []string{"/ad"}},
+		{"//span/parent::li/a", []string{"Two"}},
+		{"//span/..//a/@href", []string{"/news/2"}},
+		{"//b/ancestor::div/@id", []string{"news"}},
+		{"//a[@title]/@title", []string{"first"}},
+		{"//a[contains(@href, 'news') and starts-with(., 'T')]/@href", []string{"/news/2"}},
+		{"//a[normalize-space()='Three']/@href", []string{"/news/3"}},
+		{"//div[@id='news']//b | //div[2]/a", []string{"3", "About"}},
+		{"/html/body/div[2]/a/@href", []string{"/about"}},
+		{"count(//li)", []string{"4"}},
+		{"count(//li) - 1", []string{"3"}},
+		{"string(//p/b) = 3", []string{"true"}},
+		{"//li[a/@href='/ad']/@class", []string{"ad"}},
+		{"name(//*[@id])", []string{"div"}},
+		{"concat(//li[1]/a, '-', //li[3]/a)", []string{"One-Two"}},
+		{"//li[position() = last() - 1]/a/@href", []string{"/news/2"}},
+		{"//ul/descendant::a[1]/@href", []string{"/news/1"}},
+	}
+	for _, tc := range tcs {
+		got, err := XPathStrings(doc, tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: want %q, got %q", tc.expr, tc.want, got)
+		}
+	}
+
+	nodes, err := XPathNodes(doc, "//li/a")
+	if err != nil || len(nodes) != 4 || nodes[0].Data != "a" {
+		t.Errorf("want 4 anchors, got %v %v", nodes, err)
+	}
+	// relative to a context node
+	ul := MustCompileXPath("//ul").Nodes(doc)[0]
+	if got := MustCompileXPath("li[1]/a/@href").Strings(ul); !reflect.DeepEqual(got, []string{"/news/1"}) {
+		t.Errorf("want /news/1 relative to ul, got %q", got)
+	}
+	if nodes := MustCompileXPath("//a/@href").Nodes(doc); nodes != nil {
+		t.Errorf("want no nodes for attributes, got %v", nodes)
+	}
+}
+
+func TestCompileXPathError(t *testing.T) {
+	for _, expr := range []string{"", "//", "//a[", "//a[@href='x]", "foo::a", "bar()", "//a)", "count()"} {
+		if _, err := CompileXPath(expr); err == nil {
+			t.Errorf("%q: want error", expr)
+		}
+	}
+}